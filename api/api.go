@@ -3,59 +3,137 @@ package api
 import (
 	_ "app/api/docs"
 	"app/api/handler"
+	"app/api/models"
 
 	"app/config"
 	"app/pkg/logger"
+	"app/pkg/metrics"
 	"app/storage"
+	"app/storage/metered"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"     // swagger embed files
 	ginSwagger "github.com/swaggo/gin-swagger" // gin-swagger middleware
 )
 
-func NewApi(r *gin.Engine, cfg *config.Config, store storage.StorageI, logger logger.LoggerI) {
-	handler := handler.NewHandler(cfg, store, logger)
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name Authorization
+//
+// NewApi registers the routes on r and returns their handler.
+func NewApi(r *gin.Engine, cfg *config.Config, store storage.StorageI, logger logger.LoggerI, metrics *metrics.Metrics) *handler.Handler {
+	err := handler.RegisterValidators()
+	if err != nil {
+		logger.Panic("Error registering validators: " + err.Error())
+	}
 
+	handler := handler.NewHandler(cfg, metered.New(store, metrics), logger, metrics)
+
+	r.Use(handler.MetricsMiddleware())
+
+	// probes
+	r.GET("/healthz", handler.Healthz)
+	r.GET("/readyz", handler.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// public api
 	r.POST("/register", handler.Register)
 	r.POST("/login", handler.Login)
+	r.POST("/token/refresh", handler.RefreshToken)
+
+	logout := r.Group("/logout", handler.AuthMiddleware())
+	{
+		logout.POST("", handler.Logout)
+		logout.POST("/all", handler.LogoutAll)
+	}
 
 	// user api
-	r.POST("/user", handler.CreateUser)
-	r.GET("/user/:id", handler.GetByIdUser)
-	r.GET("/user", handler.GetListUser)
-	r.PUT("/user/:id", handler.UpdateUser)
-	r.DELETE("/user/:id", handler.DeleteUser)
+	user := r.Group("/user", handler.AuthMiddleware())
+	{
+		user.POST("", handler.RequirePermission(models.PermissionUserWrite), handler.CreateUser)
+		user.GET("/:id", handler.RequirePermission(models.PermissionUserRead), handler.GetByIdUser)
+		user.GET("", handler.RequirePermission(models.PermissionUserRead), handler.GetListUser)
+		user.PUT("/:id", handler.RequirePermission(models.PermissionUserWrite), handler.UpdateUser)
+		user.PATCH("/:id", handler.RequirePermission(models.PermissionUserWrite), handler.PatchUser)
+		user.DELETE("/:id", handler.RequirePermission(models.PermissionUserDelete), handler.DeleteUser)
+		user.POST("/:id/restore", handler.RequirePermission(models.PermissionUserDelete), handler.RestoreUser)
+
+		user.GET("/:id/roles", handler.RequirePermission(models.PermissionRoleRead), handler.GetUserRoles)
+		user.POST("/:id/roles", handler.RequirePermission(models.PermissionRoleAssign), handler.AssignRole)
+		user.DELETE("/:id/roles/:role", handler.RequirePermission(models.PermissionRoleAssign), handler.RevokeRole)
+	}
+
+	// role api
+	role := r.Group("/role", handler.AuthMiddleware())
+	{
+		role.GET("", handler.RequirePermission(models.PermissionRoleRead), handler.GetListRole)
+	}
 
 	// category api
-	r.POST("/category", handler.CreateCategory)
-	r.GET("/category/:id", handler.GetByIdCategory)
-	r.GET("/category", handler.GetListCategory)
-	r.PUT("/category/:id", handler.UpdateCategory)
-	r.DELETE("/category/:id", handler.DeleteCategory)
+	category := r.Group("/category", handler.AuthMiddleware())
+	{
+		category.POST("", handler.RequirePermission(models.PermissionCategoryWrite), handler.CreateCategory)
+		category.GET("/tree", handler.GetCategoryTree)
+		category.GET("/:id", handler.GetByIdCategory)
+		category.GET("", handler.GetListCategory)
+		category.PUT("/:id", handler.RequirePermission(models.PermissionCategoryWrite), handler.UpdateCategory)
+		category.PATCH("/:id", handler.RequirePermission(models.PermissionCategoryWrite), handler.PatchCategory)
+		category.DELETE("/:id", handler.RequirePermission(models.PermissionCategoryWrite), handler.DeleteCategory)
+		category.POST("/:id/restore", handler.RequirePermission(models.PermissionCategoryWrite), handler.RestoreCategory)
+	}
 
 	// product api
-	r.POST("/product", handler.CreateProduct)
-	r.GET("/product/:id", handler.GetByIdProduct)
-	r.GET("/product", handler.GetListProduct)
-	r.PUT("/product/:id", handler.UpdateProduct)
-	r.DELETE("/product/:id", handler.DeleteProduct)
+	product := r.Group("/product", handler.AuthMiddleware())
+	{
+		product.POST("", handler.RequirePermission(models.PermissionProductWrite), handler.CreateProduct)
+		product.GET("/:id", handler.GetByIdProduct)
+		product.GET("", handler.GetListProduct)
+		product.PUT("/:id", handler.RequirePermission(models.PermissionProductWrite), handler.UpdateProduct)
+		product.PATCH("/:id", handler.RequirePermission(models.PermissionProductWrite), handler.PatchProduct)
+		product.DELETE("/:id", handler.RequirePermission(models.PermissionProductDelete), handler.DeleteProduct)
+		product.POST("/:id/restore", handler.RequirePermission(models.PermissionProductDelete), handler.RestoreProduct)
+	}
 
 	// client api
-	r.POST("/client", handler.CreateClient)
-	r.GET("/client/:id", handler.GetByIdClient)
-	r.GET("/client", handler.GetListClient)
-	r.PUT("/client/:id", handler.UpdateClient)
-	r.DELETE("/client/:id", handler.DeleteClient)
+	client := r.Group("/client", handler.AuthMiddleware())
+	{
+		client.POST("", handler.RequirePermission(models.PermissionClientWrite), handler.CreateClient)
+		client.GET("/:id", handler.GetByIdClient)
+		client.GET("", handler.GetListClient)
+		client.PUT("/:id", handler.RequirePermission(models.PermissionClientWrite), handler.UpdateClient)
+		client.PATCH("/:id", handler.RequirePermission(models.PermissionClientWrite), handler.PatchClient)
+		client.DELETE("/:id", handler.RequirePermission(models.PermissionClientDelete), handler.DeleteClient)
+		client.POST("/:id/restore", handler.RequirePermission(models.PermissionClientDelete), handler.RestoreClient)
+	}
 
 	// order api
-	r.POST("/order", handler.CreateOrder)
-	r.GET("/order/:id", handler.GetByIdOrder)
-	r.GET("/order", handler.GetListOrder)
-	r.PUT("/order/:id", handler.UpdateOrder)
-	r.DELETE("/order/:id", handler.DeleteOrder)
-	r.POST("/order_item/", handler.CreateOrderItem)
-	r.DELETE("/order_item/:id", handler.DeleteOrderItem)
+	order := r.Group("/order", handler.AuthMiddleware())
+	{
+		order.POST("", handler.RequirePermission(models.PermissionOrderCreate), handler.CreateOrder)
+		order.GET("/:id", handler.GetByIdOrder)
+		order.GET("", handler.GetListOrder)
+		order.PUT("/:id", handler.RequirePermission(models.PermissionOrderWrite), handler.UpdateOrder)
+		order.PATCH("/:id", handler.RequirePermission(models.PermissionOrderWrite), handler.PatchOrder)
+		order.DELETE("/:id", handler.RequirePermission(models.PermissionOrderDelete), handler.DeleteOrder)
+		order.POST("/:id/restore", handler.RequirePermission(models.PermissionOrderDelete), handler.RestoreOrder)
+
+		order.GET("/:id/history", handler.GetOrderHistory)
+		order.POST("/:id/confirm", handler.RequirePermission(models.PermissionOrderWrite), handler.ConfirmOrder)
+		order.POST("/:id/pay", handler.RequirePermission(models.PermissionOrderWrite), handler.PayOrder)
+		order.POST("/:id/ship", handler.RequirePermission(models.PermissionOrderWrite), handler.ShipOrder)
+		order.POST("/:id/deliver", handler.RequirePermission(models.PermissionOrderWrite), handler.DeliverOrder)
+		order.POST("/:id/cancel", handler.RequirePermission(models.PermissionOrderWrite), handler.CancelOrder)
+		order.POST("/:id/refund", handler.RequirePermission(models.PermissionOrderWrite), handler.RefundOrder)
+	}
+
+	orderItem := r.Group("/order_item", handler.AuthMiddleware())
+	{
+		orderItem.POST("/", handler.RequirePermission(models.PermissionOrderCreate), handler.CreateOrderItem)
+		orderItem.DELETE("/:id", handler.RequirePermission(models.PermissionOrderCreate), handler.DeleteOrderItem)
+	}
 
 	url := ginSwagger.URL("swagger/doc.json") // The url pointing to API definition
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
+
+	return handler
 }
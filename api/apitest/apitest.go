@@ -0,0 +1,206 @@
+// Package apitest serves the api in process for tests. Requests go
+// through the gin engine NewApi builds, without a listening server, and
+// carry the access token of the logged in user.
+package apitest
+
+import (
+	"app/api"
+	"app/api/handler"
+	"app/api/models"
+	"app/config"
+	"app/pkg/helper"
+	"app/pkg/logger"
+	"app/pkg/metrics"
+	"app/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Server is the api on top of store. It is safe for concurrent use.
+type Server struct {
+	engine  *gin.Engine
+	handler *handler.Handler
+	store   storage.StorageI
+	metrics *metrics.Metrics
+
+	mu    sync.RWMutex
+	token string
+}
+
+// NewServer builds the api on top of store, logging to log. Its metrics
+// are kept in a registry of its own.
+func NewServer(store storage.StorageI, log logger.LoggerI) *Server {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		Environment:   config.TestMode,
+		AuthSecretKey: "apitest",
+		DefaultLimit:  10,
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	m := metrics.New(prometheus.NewRegistry())
+
+	h := api.NewApi(r, cfg, store, log, m)
+
+	return &Server{
+		engine:  r,
+		handler: h,
+		store:   store,
+		metrics: m,
+	}
+}
+
+// Store is the storage the api runs on, for setting up test data
+// directly.
+func (s *Server) Store() storage.StorageI {
+	return s.store
+}
+
+// Metrics is what the api records, also served on /metrics.
+func (s *Server) Metrics() *metrics.Metrics {
+	return s.metrics
+}
+
+// ShuttingDown makes readiness fail like a server that got SIGTERM.
+func (s *Server) ShuttingDown() {
+	s.handler.ShuttingDown()
+}
+
+// Handler is the gin engine of the api.
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
+
+// CreateUser creates a user with the roles, or adds the roles when the
+// login exists already.
+func (s *Server) CreateUser(login, password string, roles ...string) error {
+	ctx := context.Background()
+
+	user, err := s.store.User().GetCredentials(ctx, &models.UserPrimaryKey{Login: login})
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	if err != nil {
+		hash, err := helper.HashPassword(password)
+		if err != nil {
+			return err
+		}
+
+		user = &models.UserCredentials{Login: login}
+		user.Id, err = s.store.User().Create(ctx, &models.CreateUser{
+			FirstName:   "Test",
+			LastName:    "Test",
+			Login:       login,
+			Password:    hash,
+			PhoneNumber: "+998900000000",
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, role := range roles {
+		_, err = s.store.Role().AssignRole(ctx, &models.UserRole{UserId: user.Id, Role: role})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Login logs in through the api, the following requests carry the access
+// token of the user.
+func (s *Server) Login(login, password string) error {
+	var tokens models.LoginResponse
+
+	resp, err := s.Do(http.MethodPost, "/login", &models.Login{Login: login, Password: password}, &tokens)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("login %s: status %d", login, resp.StatusCode)
+	}
+
+	s.mu.Lock()
+	s.token = tokens.AccessToken
+	s.mu.Unlock()
+
+	return nil
+}
+
+// LoginAdmin creates a user with the admin role and logs in as it.
+func (s *Server) LoginAdmin() error {
+	const login, password = "apitest_admin", "apitest_password"
+
+	err := s.CreateUser(login, password, models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	return s.Login(login, password)
+}
+
+// Do sends a request with req as its json body, nil for none, and decodes
+// the json response body into res unless it is nil. The access token of
+// the logged in user is sent unless headers set Authorization themselves.
+func (s *Server) Do(method, path string, req, res interface{}, headers ...Header) (*http.Response, error) {
+	var body io.Reader
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	request := httptest.NewRequest(method, path, body)
+	request.Header.Set("Accept", "application/json")
+	if req != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	s.mu.RLock()
+	if len(s.token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	s.mu.RUnlock()
+
+	for _, h := range headers {
+		request.Header.Set(h.Key, h.Value)
+	}
+
+	recorder := httptest.NewRecorder()
+	s.engine.ServeHTTP(recorder, request)
+
+	resp := recorder.Result()
+
+	if res != nil && recorder.Body.Len() > 0 {
+		err := json.Unmarshal(recorder.Body.Bytes(), res)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, nil
+}
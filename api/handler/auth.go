@@ -4,11 +4,14 @@ import (
 	"app/api/models"
 	"app/config"
 	"app/pkg/helper"
+	"app/pkg/logger"
+	"app/storage"
 	"context"
 	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // Register godoc
@@ -21,37 +24,44 @@ import (
 // @Produce json
 // @Param register body models.Register true "CreateRegisterRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) Register(c *gin.Context) {
 
 	var createUser models.CreateUser
 
 	err := c.ShouldBindJSON(&createUser) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "register user", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "register user", err)
+		return
+	}
+
+	createUser.Password, err = helper.HashPassword(createUser.Password)
+	if err != nil {
+		h.handleError(c, "helper.hashPassword", err)
 		return
 	}
 
 	id, err := h.storages.User().Create(context.Background(), &createUser)
 	if err != nil {
-		if err.Error() == "duplicate key value violates unique constraint \"users_login_key\" (SQLSTATE 23505)" {
-			h.handlerResponse(c, "storage.user.create", http.StatusBadRequest, "user already exists please login!")
-			return
-		}
-		h.handlerResponse(c, "storage.user.create", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.create", err)
 		return
 	}
 
 	user, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.getByID", err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, user)
 }
 
+// dummyPasswordHash is checked when the login does not exist, so unknown
+// logins take as long to refuse as wrong passwords.
+const dummyPasswordHash = "$2a$10$lJY9EfyaUdl4BUYgHTeTY.uiGgRxBVVvHJcwq7ZMw7vQkd6womLcG"
+
 // Login godoc
 // @ID login
 // @Router /login [POST]
@@ -62,43 +72,280 @@ func (h *Handler) Register(c *gin.Context) {
 // @Produce json
 // @Param logim body models.Login true "LoginRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 401 {object} Response{data=ErrorResponse} "Unauthorized"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) Login(c *gin.Context) {
 
 	var login models.Login
 
 	err := c.ShouldBindJSON(&login) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "login user", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "login user", err)
+		return
+	}
+
+	// unknown logins and wrong passwords get the same answer, so the api
+	// does not tell which logins exist
+	resp, err := h.storages.User().GetCredentials(context.Background(), &models.UserPrimaryKey{Login: login.Login})
+	if errors.Is(err, storage.ErrNotFound) {
+		helper.ComparePassword(dummyPasswordHash, login.Password)
+		h.handlerResponse(c, "login user", http.StatusUnauthorized, "login or password is wrong")
+		return
+	}
+	if err != nil {
+		h.handleError(c, "storage.user.getCredentials", err)
+		return
+	}
+
+	if !helper.ComparePassword(resp.Password, login.Password) {
+		h.handlerResponse(c, "login user", http.StatusUnauthorized, "login or password is wrong")
+		return
+	}
+
+	// rows created before hashing was introduced still keep the plain text
+	// password, replace it with a hash now that we know it
+	if !helper.IsHashedPassword(resp.Password) {
+		h.rehashPassword(resp.Id, login.Password)
+	}
+
+	tokens, err := h.issueTokens(c, h.storages, resp.Id, uuid.NewString())
+	if err != nil {
+		h.handleError(c, "issue tokens", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokens.LoginResponse)
+}
+
+// Refresh Token godoc
+// @ID refresh_token
+// @Router /token/refresh [POST]
+// @Summary Refresh Token
+// @Description Rotate refresh token and issue a new access token
+// @Tags Login
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshTokenRequest true "RefreshTokenRequest"
+// @Success 201 {object} models.LoginResponse "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 401 {object} Response{data=ErrorResponse} "Unauthorized"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RefreshToken(c *gin.Context) {
+
+	var refresh models.RefreshTokenRequest
+
+	err := c.ShouldBindJSON(&refresh) // parse req body to given type struct
+	if err != nil || len(refresh.RefreshToken) <= 0 {
+		h.handlerResponse(c, "refresh token", http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	session, err := h.storages.Session().GetByID(context.Background(), &models.SessionPrimaryKey{
+		RefreshTokenHash: helper.HashToken(refresh.RefreshToken),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.session.getByID", http.StatusUnauthorized, "invalid refresh token")
+			return
+		}
+		h.handleError(c, "storage.session.getByID", err)
+		return
+	}
+
+	// a rotated refresh token must never come back, if it does the whole
+	// token family is considered stolen
+	if session.Revoked {
+		h.revokeFamily(session.FamilyId)
+		h.handlerResponse(c, "refresh token", http.StatusUnauthorized, errRefreshTokenReused.Error())
 		return
 	}
 
-	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Login: login.Login})
+	if session.Expired {
+		h.handlerResponse(c, "refresh token", http.StatusUnauthorized, "refresh token expired")
+		return
+	}
+
+	// the new session only survives if the old one is revoked by us
+	var tokens *issuedTokens
+	err = h.storages.WithTx(context.Background(), func(tx storage.StorageI) error {
+
+		tokens, err = h.issueTokens(c, tx, session.UserId, session.FamilyId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Session().Revoke(context.Background(), &models.RevokeSession{
+			Id:         session.Id,
+			ReplacedBy: tokens.sessionId,
+		})
+		if err != nil {
+			return err
+		}
+
+		// somebody else rotated the same token concurrently
+		if rows <= 0 {
+			return errRefreshTokenReused
+		}
+
+		return nil
+	})
 	if err != nil {
-		if err.Error() == "no rows in result set" {
-			h.handlerResponse(c, "storage.user.getByID", http.StatusNotFound, "user not found please register first")
+		if errors.Is(err, errRefreshTokenReused) {
+			h.revokeFamily(session.FamilyId)
+			h.handlerResponse(c, "refresh token", http.StatusUnauthorized, err.Error())
 			return
 		}
+		h.handleError(c, "refresh token", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, tokens.LoginResponse)
+}
+
+// Logout godoc
+// @ID logout
+// @Router /logout [POST]
+// @Summary Logout
+// @Description Revoke the current session
+// @Tags Login
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Success 204 {object} Response{data=string} "Success Request"
+// @Response 401 {object} Response{data=ErrorResponse} "Unauthorized"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) Logout(c *gin.Context) {
 
-		h.handlerResponse(c, "storage.user.getByID", http.StatusInternalServerError, err.Error())
+	session, err := h.storages.Session().GetByID(context.Background(), &models.SessionPrimaryKey{Id: c.GetString(ctxSessionIdKey)})
+	if err != nil {
+		h.handleError(c, "storage.session.getByID", err)
+		return
+	}
+
+	_, err = h.storages.Session().RevokeFamily(context.Background(), &models.RevokeSession{FamilyId: session.FamilyId})
+	if err != nil {
+		h.handleError(c, "storage.session.revokeFamily", err)
 		return
 	}
 
-	if resp.Password != login.Password {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusBadRequest, "credentials are wrong")
+	h.handlerResponse(c, "logout", http.StatusNoContent, nil)
+}
+
+// Logout All godoc
+// @ID logout_all
+// @Router /logout/all [POST]
+// @Summary Logout All
+// @Description Revoke every session of the current user
+// @Tags Login
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Success 204 {object} Response{data=string} "Success Request"
+// @Response 401 {object} Response{data=ErrorResponse} "Unauthorized"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) LogoutAll(c *gin.Context) {
+
+	_, err := h.storages.Session().RevokeAll(context.Background(), &models.RevokeSession{UserId: c.GetString(ctxUserIdKey)})
+	if err != nil {
+		h.handleError(c, "storage.session.revokeAll", err)
 		return
 	}
 
+	h.handlerResponse(c, "logout all", http.StatusNoContent, nil)
+}
+
+type issuedTokens struct {
+	models.LoginResponse
+	sessionId string
+}
+
+var errRefreshTokenReused = errors.New("refresh token reuse detected, please login again")
+
+// issueTokens opens a new session in the given token family and returns
+// an access/refresh token pair bound to it.
+func (h *Handler) issueTokens(c *gin.Context, store storage.StorageI, userId, familyId string) (*issuedTokens, error) {
+
+	refreshToken, err := helper.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionId, err := store.Session().Create(context.Background(), &models.CreateSession{
+		UserId:           userId,
+		FamilyId:         familyId,
+		RefreshTokenHash: helper.HashToken(refreshToken),
+		UserAgent:        c.Request.UserAgent(),
+		IpAddress:        c.ClientIP(),
+		TTL:              config.RefreshTokenExpiredAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userRoles, err := store.Role().GetUserRoles(context.Background(), &models.UserPrimaryKey{Id: userId})
+	if err != nil {
+		return nil, err
+	}
+
 	data := map[string]interface{}{
-		"Id": resp.Id,
+		"user_id":     userId,
+		"session_id":  sessionId,
+		"client_type": primaryRole(userRoles.Roles),
+		"roles":       userRoles.Roles,
+		"permissions": userRoles.Permissions,
+	}
+
+	accessToken, err := helper.GenerateJWT(data, config.AccessTokenExpiredAt, h.cfg.AuthSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &issuedTokens{
+		LoginResponse: models.LoginResponse{
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+		},
+		sessionId: sessionId,
+	}, nil
+}
+
+// primaryRole returns the most privileged of the given roles, it is put into
+// the client_type claim of the access token.
+func primaryRole(roles []string) string {
+	for _, role := range []string{models.RoleAdmin, models.RoleManager, models.RoleCashier} {
+		for _, r := range roles {
+			if r == role {
+				return role
+			}
+		}
 	}
 
-	token, err := helper.GenerateJWT(data, config.TimeExpiredAt, h.cfg.AuthSecretKey)
+	return ""
+}
+
+func (h *Handler) revokeFamily(familyId string) {
+
+	_, err := h.storages.Session().RevokeFamily(context.Background(), &models.RevokeSession{FamilyId: familyId})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusBadRequest, errors.New("token error"))
+		h.logger.Error("storage.session.revokeFamily", logger.Error(err))
+	}
+}
+
+func (h *Handler) rehashPassword(id, password string) {
+
+	hashed, err := helper.HashPassword(password)
+	if err != nil {
+		h.logger.Error("helper.hashPassword", logger.Error(err))
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.LoginResponse{AccessToken: token})
+	_, err = h.storages.User().UpdatePassword(context.Background(), &models.UpdateUserPassword{
+		Id:       id,
+		Password: hashed,
+	})
+	if err != nil {
+		h.logger.Error("storage.user.updatePassword", logger.Error(err))
+	}
 }
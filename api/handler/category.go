@@ -2,7 +2,9 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,31 +16,37 @@ import (
 // @Summary Create Category
 // @Description Create Category
 // @Tags Category
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param category body models.CreateCategory true "CreateCategoryRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateCategory(c *gin.Context) {
 
 	var createCategory models.CreateCategory
 
 	err := c.ShouldBindJSON(&createCategory) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create category", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create category", err)
+		return
+	}
+
+	if !h.categoryExists(c, createCategory.ParentId) {
 		return
 	}
 
 	id, err := h.storages.Category().Create(context.Background(), &createCategory)
 	if err != nil {
-		h.handlerResponse(c, "storage.category.create", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.create", err)
 		return
 	}
 
 	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.category.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.getByID", err)
 		return
 	}
 
@@ -51,22 +59,31 @@ func (h *Handler) CreateCategory(c *gin.Context) {
 // @Summary Get By ID Category
 // @Description Get By ID Category
 // @Tags Category
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Header 200 {string} ETag "version of the resource"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetByIdCategory(c *gin.Context) {
 
 	id := c.Param("id")
 
-	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id, IncludeDeleted: includeDeleted})
 	if err != nil {
-		h.handlerResponse(c, "storage.category.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
 	h.handlerResponse(c, "get category by id", http.StatusAccepted, resp)
 }
 
@@ -76,14 +93,16 @@ func (h *Handler) GetByIdCategory(c *gin.Context) {
 // @Summary Get List Category
 // @Description Get List Category
 // @Tags Category
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
 // @Param search query string false "search"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetListCategory(c *gin.Context) {
 
 	offset, err := h.getOffsetQuery(c.Query("offset"))
@@ -98,13 +117,19 @@ func (h *Handler) GetListCategory(c *gin.Context) {
 		return
 	}
 
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
 	resp, err := h.storages.Category().GetList(context.Background(), &models.GetListCategoryRequest{
-		Offset: offset,
-		Limit:  limit,
-		Search: c.Query("search"),
+		Offset:         offset,
+		Limit:          limit,
+		Search:         c.Query("search"),
+		IncludeDeleted: includeDeleted,
 	})
 	if err != nil {
-		h.handlerResponse(c, "storage.category.getlist", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.getlist", err)
 		return
 	}
 
@@ -112,53 +137,162 @@ func (h *Handler) GetListCategory(c *gin.Context) {
 	h.handlerResponse(c, "get list category response", http.StatusOK, resp)
 }
 
+// Get Category Tree godoc
+// @ID get_category_tree
+// @Router /category/tree [GET]
+// @Summary Get Category Tree
+// @Description Get all categories nested under their parents
+// @Tags Category
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Success 200 {object} Response{data=models.GetCategoryTreeResponse} "Success Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) GetCategoryTree(c *gin.Context) {
+
+	resp, err := h.storages.Category().GetTree(context.Background())
+	if err != nil {
+		h.handleError(c, "storage.category.getTree", err)
+		return
+	}
+
+	h.handlerResponse(c, "get category tree response", http.StatusOK, resp)
+}
+
 // Update Category godoc
 // @ID update_category
 // @Router /category/{id} [PUT]
 // @Summary Update Category
 // @Description Update Category
 // @Tags Category
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param category body models.UpdateCategory true "UpdateCategoryRequest"
-// @Success 202 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Success 200 {object} models.Category "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 409 {object} Response{data=ErrorResponse} "Category Cycle"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) UpdateCategory(c *gin.Context) {
 
 	var updateCategory models.UpdateCategory
 
 	id := c.Param("id")
 
+	version, ok := h.ifMatch(c, "update category")
+	if !ok {
+		return
+	}
+
 	err := c.ShouldBindJSON(&updateCategory)
 	if err != nil {
-		h.handlerResponse(c, "update category", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "update category", err)
 		return
 	}
 
 	updateCategory.Id = id
 
+	if !h.categoryExists(c, updateCategory.ParentId) {
+		return
+	}
+
+	updateCategory.Version = version
+
 	rowsAffected, err := h.storages.Category().Update(context.Background(), &updateCategory)
 	if err != nil {
-		h.handlerResponse(c, "storage.category.update", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.update", err)
 		return
 	}
 
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.category.update", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.category.update", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.category.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
 	c.JSON(http.StatusOK, resp)
+}
+
+// Patch Category godoc
+// @ID patch_category
+// @Router /category/{id} [PATCH]
+// @Summary Patch Category
+// @Description Change only the fields present in a JSON Merge Patch (RFC 7396) document, null clears a field
+// @Tags Category
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
+// @Param category body models.PatchCategory true "PatchCategoryRequest"
+// @Success 200 {object} models.Category "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 415 {object} Response{data=ErrorResponse} "Unsupported Media Type"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PatchCategory(c *gin.Context) {
+
+	id := c.Param("id")
+
+	version, ok := h.ifMatch(c, "patch category")
+	if !ok {
+		return
+	}
+
+	current, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.category.getByID", err)
+		return
+	}
+
+	updateCategory := models.UpdateCategory{
+		Id:       current.Id,
+		ParentId: current.ParentId,
+		Name:     current.Name,
+	}
+	patchCategory := models.PatchCategory{Id: id}
+
+	if !h.bindMergePatch(c, "patch category", &updateCategory, &patchCategory) {
+		return
+	}
+
+	if patchCategory.ParentId != nil && !h.categoryExists(c, *patchCategory.ParentId) {
+		return
+	}
+
+	patchCategory.Version = version
+
+	rowsAffected, err := h.storages.Category().Patch(context.Background(), &patchCategory)
+	if err != nil {
+		h.handleError(c, "storage.category.patch", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.category.patch", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.category.getByID", err)
+		return
+	}
 
-	h.handlerResponse(c, "update category", http.StatusAccepted, resp)
+	setETag(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
 }
 
 // DELETE Category godoc
@@ -167,26 +301,90 @@ func (h *Handler) UpdateCategory(c *gin.Context) {
 // @Summary Delete Category
 // @Description Delete Category
 // @Tags Category
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param category body models.CategoryPrimaryKey true "DeleteCategoryRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteCategory(c *gin.Context) {
 
 	id := c.Param("id")
 
-	rowsAffected, err := h.storages.Category().Delete(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	version, ok := h.ifMatch(c, "delete category")
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := h.storages.Category().Delete(context.Background(), &models.CategoryPrimaryKey{Id: id, Version: version})
 	if err != nil {
-		h.handlerResponse(c, "storage.category.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.category.delete", err)
 		return
 	}
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.category.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.category.delete", storage.ErrNotFound)
 		return
 	}
 
 	h.handlerResponse(c, "delete category", http.StatusNoContent, nil)
 }
+
+// categoryExists responds with 400 and reports false when the given parent
+// category does not exist, an empty id means no parent.
+func (h *Handler) categoryExists(c *gin.Context, id string) bool {
+	if len(id) == 0 {
+		return true
+	}
+
+	_, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.category.getByID", http.StatusBadRequest, "parent category not found")
+			return false
+		}
+		h.handleError(c, "storage.category.getByID", err)
+		return false
+	}
+
+	return true
+}
+
+// Restore Category godoc
+// @ID restore_category
+// @Router /category/{id}/restore [POST]
+// @Summary Restore Category
+// @Description Restore a deleted category
+// @Tags Category
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Category} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RestoreCategory(c *gin.Context) {
+
+	id := c.Param("id")
+
+	rowsAffected, err := h.storages.Category().Restore(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.category.restore", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.category.restore", http.StatusNotFound, "deleted category not found")
+		return
+	}
+
+	resp, err := h.storages.Category().GetByID(context.Background(), &models.CategoryPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.category.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "restore category", http.StatusOK, resp)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
 	"context"
 	"net/http"
 
@@ -14,31 +15,33 @@ import (
 // @Summary Create Client
 // @Description Create Client
 // @Tags Client
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param client body models.CreateClient true "CreateClientRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateClient(c *gin.Context) {
 
 	var createCustomer models.CreateClient
 
 	err := c.ShouldBindJSON(&createCustomer) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create customer", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create customer", err)
 		return
 	}
 
 	id, err := h.storages.Client().Create(context.Background(), &createCustomer)
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.create", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.create", err)
 		return
 	}
 
 	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.getByID", err)
 		return
 	}
 
@@ -51,22 +54,31 @@ func (h *Handler) CreateClient(c *gin.Context) {
 // @Summary Get By ID Client
 // @Description Get By ID Client
 // @Tags Client
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Header 200 {string} ETag "version of the resource"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetByIdClient(c *gin.Context) {
 
 	id := c.Param("id")
 
-	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id, IncludeDeleted: includeDeleted})
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
 	h.handlerResponse(c, "get customer by id", http.StatusCreated, resp)
 }
 
@@ -76,14 +88,17 @@ func (h *Handler) GetByIdClient(c *gin.Context) {
 // @Summary Get List Client
 // @Description Get List Client
 // @Tags Client
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
-// @Param search query string false "search"
-// @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Param search query string false "full text search over names and phone number, best matches first"
+// @Param cursor query string false "next_cursor of the previous page, empty for the first one; switches to keyset pagination, newest first, without count"
+// @Param include_deleted query bool false "include deleted rows, admins only"
+// @Success 200 {object} Response{data=models.GetListClientResponse} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetListClient(c *gin.Context) {
 
 	offset, err := h.getOffsetQuery(c.Query("offset"))
@@ -98,13 +113,27 @@ func (h *Handler) GetListClient(c *gin.Context) {
 		return
 	}
 
+	cursor, keyset, err := h.getCursorQuery(c)
+	if err != nil {
+		h.handlerResponse(c, "get list client", http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
 	resp, err := h.storages.Client().GetList(context.Background(), &models.GetListClientRequest{
-		Offset: offset,
-		Limit:  limit,
-		Search: c.Query("search"),
+		Offset:         offset,
+		Limit:          limit,
+		Search:         c.Query("search"),
+		IncludeDeleted: includeDeleted,
+		Cursor:         cursor,
+		Keyset:         keyset,
 	})
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.getlist", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.getlist", err)
 		return
 	}
 
@@ -117,45 +146,126 @@ func (h *Handler) GetListClient(c *gin.Context) {
 // @Summary Update Client
 // @Description Update Client
 // @Tags Client
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param client body models.UpdateClient true "UpdateClientRequest"
-// @Success 202 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Success 200 {object} Response{data=models.Client} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) UpdateClient(c *gin.Context) {
 
 	var updateCustomer models.UpdateClient
 
 	id := c.Param("id")
 
+	version, ok := h.ifMatch(c, "update client")
+	if !ok {
+		return
+	}
+
 	err := c.ShouldBindJSON(&updateCustomer)
 	if err != nil {
-		h.handlerResponse(c, "update customer", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "update customer", err)
 		return
 	}
 
 	updateCustomer.Id = id
 
+	updateCustomer.Version = version
+
 	rowsAffected, err := h.storages.Client().Update(context.Background(), &updateCustomer)
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.update", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.update", err)
 		return
 	}
 
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.customer.update", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.customer.update", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.getByID", err)
 		return
 	}
 
-	h.handlerResponse(c, "update customer", http.StatusAccepted, resp)
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "update customer", http.StatusOK, resp)
+}
+
+// Patch Client godoc
+// @ID patch_client
+// @Router /client/{id} [PATCH]
+// @Summary Patch Client
+// @Description Change only the fields present in a JSON Merge Patch (RFC 7396) document, null clears a field
+// @Tags Client
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
+// @Param client body models.PatchClient true "PatchClientRequest"
+// @Success 200 {object} models.Client "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 415 {object} Response{data=ErrorResponse} "Unsupported Media Type"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PatchClient(c *gin.Context) {
+
+	id := c.Param("id")
+
+	version, ok := h.ifMatch(c, "patch client")
+	if !ok {
+		return
+	}
+
+	current, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.client.getByID", err)
+		return
+	}
+
+	updateClient := models.UpdateClient{
+		Id:          current.Id,
+		FirstName:   current.FirstName,
+		LastName:    current.LastName,
+		PhoneNumber: current.PhoneNumber,
+	}
+	patchClient := models.PatchClient{Id: id}
+
+	if !h.bindMergePatch(c, "patch client", &updateClient, &patchClient) {
+		return
+	}
+
+	patchClient.Version = version
+
+	rowsAffected, err := h.storages.Client().Patch(context.Background(), &patchClient)
+	if err != nil {
+		h.handleError(c, "storage.client.patch", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.client.patch", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.client.getByID", err)
+		return
+	}
+
+	setETag(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
 }
 
 // DELETE Client godoc
@@ -164,26 +274,70 @@ func (h *Handler) UpdateClient(c *gin.Context) {
 // @Summary Delete Client
 // @Description Delete Client
 // @Tags Client
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param client body models.ClientPrimaryKey true "DeleteClientRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteClient(c *gin.Context) {
 
 	id := c.Param("id")
 
-	rowsAffected, err := h.storages.Client().Delete(context.Background(), &models.ClientPrimaryKey{Id: id})
+	version, ok := h.ifMatch(c, "delete client")
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := h.storages.Client().Delete(context.Background(), &models.ClientPrimaryKey{Id: id, Version: version})
 	if err != nil {
-		h.handlerResponse(c, "storage.customer.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.customer.delete", err)
 		return
 	}
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.customer.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.customer.delete", storage.ErrNotFound)
 		return
 	}
 
 	h.handlerResponse(c, "delete customer", http.StatusNoContent, nil)
 }
+
+// Restore Client godoc
+// @ID restore_client
+// @Router /client/{id}/restore [POST]
+// @Summary Restore Client
+// @Description Restore a deleted client
+// @Tags Client
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Client} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RestoreClient(c *gin.Context) {
+
+	id := c.Param("id")
+
+	rowsAffected, err := h.storages.Client().Restore(context.Background(), &models.ClientPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.client.restore", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.client.restore", http.StatusNotFound, "deleted client not found")
+		return
+	}
+
+	resp, err := h.storages.Client().GetByID(context.Background(), &models.ClientPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.client.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "restore client", http.StatusOK, resp)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"app/pkg/logger"
+	"app/storage"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorResponse is the Data of every failed response. Code is stable and
+// meant for programs, Message is meant for people and may change.
+type ErrorResponse struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError points at the request field that caused the error.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	ErrCodeBadRequest        = "bad_request"
+	ErrCodeUnauthorized      = "unauthorized"
+	ErrCodeForbidden         = "forbidden"
+	ErrCodeNotFound          = "not_found"
+	ErrCodeConflict          = "conflict"
+	ErrCodeForeignKey        = "foreign_key_violation"
+	ErrCodeCheckViolation    = "check_violation"
+	ErrCodeVersionMismatch   = "precondition_failed"
+	ErrCodeVersionRequired   = "precondition_required"
+	ErrCodeInsufficientStock = "insufficient_stock"
+	ErrCodeOrderFrozen       = "order_frozen"
+	ErrCodeInvalidTransition = "invalid_transition"
+	ErrCodeCategoryCycle     = "category_cycle"
+	ErrCodeUnprocessable     = "unprocessable_entity"
+	ErrCodeInternal          = "internal_error"
+)
+
+// storageErrors maps the errors of the storage layer to their responses,
+// the first match wins.
+var storageErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{storage.ErrNotFound, http.StatusNotFound, ErrCodeNotFound},
+	{storage.ErrConflict, http.StatusConflict, ErrCodeConflict},
+	{storage.ErrForeignKey, http.StatusUnprocessableEntity, ErrCodeForeignKey},
+	{storage.ErrCheckViolation, http.StatusUnprocessableEntity, ErrCodeCheckViolation},
+	{storage.ErrVersionMismatch, http.StatusPreconditionFailed, ErrCodeVersionMismatch},
+	{storage.ErrInsufficientStock, http.StatusConflict, ErrCodeInsufficientStock},
+	{storage.ErrOrderFrozen, http.StatusConflict, ErrCodeOrderFrozen},
+	{storage.ErrInvalidTransition, http.StatusConflict, ErrCodeInvalidTransition},
+	{storage.ErrCategoryCycle, http.StatusConflict, ErrCodeCategoryCycle},
+}
+
+// handleError responds to a failed storage call. Known storage errors get
+// their own status and code, anything else is a 500 whose details are only
+// logged.
+func (h *Handler) handleError(c *gin.Context, path string, err error) {
+	for _, e := range storageErrors {
+		if !errors.Is(err, e.err) {
+			continue
+		}
+
+		resp := ErrorResponse{
+			Code:    e.code,
+			Message: err.Error(),
+		}
+
+		var storageErr *storage.Error
+		if errors.As(err, &storageErr) && len(storageErr.Field) > 0 {
+			resp.Message = storageErr.Err.Error()
+			resp.Fields = []FieldError{{Field: storageErr.Field, Code: e.code}}
+		}
+
+		h.handlerResponse(c, path, e.status, resp)
+		return
+	}
+
+	h.logger.Error(path, logger.Error(err))
+	h.handlerResponse(c, path, http.StatusInternalServerError, ErrorResponse{
+		Code:    ErrCodeInternal,
+		Message: "internal server error",
+	})
+}
+
+// errorCode is the code of a failed response that has no more specific
+// one.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrCodeBadRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusPreconditionFailed:
+		return ErrCodeVersionMismatch
+	case http.StatusPreconditionRequired:
+		return ErrCodeVersionRequired
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnprocessable
+	default:
+		return ErrCodeInternal
+	}
+}
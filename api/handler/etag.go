@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag exposes the version of a resource as its strong entity tag.
+func setETag(c *gin.Context, version int) {
+	if version > 0 {
+		c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+	}
+}
+
+// ifMatch returns the version the If-Match header of the request expects,
+// 0 when there is none or it is "*". A tag that is not one we hand out can
+// never match, so it responds with 412 itself and returns false.
+func (h *Handler) ifMatch(c *gin.Context, path string) (int, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if len(value) == 0 || value == "*" {
+		return 0, true
+	}
+
+	tag, err := strconv.Unquote(value)
+	if err == nil {
+		version, err := strconv.Atoi(tag)
+		if err == nil && version > 0 {
+			return version, true
+		}
+	}
+
+	h.handlerResponse(c, path, http.StatusPreconditionFailed, "If-Match must be the ETag of the resource")
+	return 0, false
+}
+
+// requireVersion responds with 428 and returns false when the request has
+// no If-Match naming a version. Writes of absolute values that other
+// requests change in the meantime, like the stock orders reserve, need it
+// so that they cannot undo those changes.
+func (h *Handler) requireVersion(c *gin.Context, path string, version int) bool {
+	if version > 0 {
+		return true
+	}
+
+	h.handlerResponse(c, path, http.StatusPreconditionRequired, "If-Match with the ETag of the resource is required")
+	return false
+}
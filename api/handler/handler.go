@@ -1,10 +1,17 @@
 package handler
 
 import (
+	"app/api/models"
 	"app/config"
+	"app/pkg/helper"
 	"app/pkg/logger"
+	"app/pkg/metrics"
 	"app/storage"
+	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +20,10 @@ type Handler struct {
 	cfg      *config.Config
 	logger   logger.LoggerI
 	storages storage.StorageI
+	metrics  *metrics.Metrics
+
+	// shuttingDown fails readiness while the server drains
+	shuttingDown atomic.Bool
 }
 
 type Response struct {
@@ -21,15 +32,24 @@ type Response struct {
 	Data        interface{}
 }
 
-func NewHandler(cfg *config.Config, store storage.StorageI, logger logger.LoggerI) *Handler {
+func NewHandler(cfg *config.Config, store storage.StorageI, logger logger.LoggerI, metrics *metrics.Metrics) *Handler {
 	return &Handler{
 		cfg:      cfg,
 		logger:   logger,
 		storages: store,
+		metrics:  metrics,
 	}
 }
 
 func (h *Handler) handlerResponse(c *gin.Context, path string, code int, message interface{}) {
+	// failures always carry an ErrorResponse
+	if text, ok := message.(string); ok && code >= 400 {
+		message = ErrorResponse{
+			Code:    errorCode(code),
+			Message: text,
+		}
+	}
+
 	response := Response{
 		Status:      code,
 		Description: path,
@@ -62,3 +82,97 @@ func (h *Handler) getLimitQuery(limit string) (int, error) {
 
 	return strconv.Atoi(limit)
 }
+
+// getCursorQuery reports whether keyset pagination was requested with the
+// cursor query, an empty cursor asks for the first page.
+func (h *Handler) getCursorQuery(c *gin.Context) (string, bool, error) {
+	cursor, ok := c.GetQuery("cursor")
+	if !ok || len(cursor) == 0 {
+		return "", ok, nil
+	}
+
+	_, _, err := helper.DecodeCursor(cursor)
+	if err != nil {
+		return "", false, err
+	}
+
+	return cursor, true, nil
+}
+
+func (h *Handler) getFloatQuery(value string) (*float64, error) {
+	if len(value) <= 0 {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// getTimeQuery accepts a date or an RFC3339 timestamp and returns it in the
+// timestamp format of the database. A bare date used as the upper bound of
+// a range covers the whole day.
+func (h *Handler) getTimeQuery(value string, endOfDay bool) (string, error) {
+	if len(value) <= 0 {
+		return "", nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return "", err
+		}
+
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Microsecond)
+		}
+	}
+
+	return t.Format("2006-01-02 15:04:05.999999"), nil
+}
+
+func (h *Handler) isOneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// includeDeleted reads the include_deleted query, only admins may list
+// deleted rows. On a forbidden request it responds with 403 and ok is
+// false.
+func (h *Handler) includeDeleted(c *gin.Context) (include bool, ok bool) {
+	if c.Query("include_deleted") != "true" {
+		return false, true
+	}
+
+	if !h.isOneOf(models.RoleAdmin, c.GetStringSlice(ctxRolesKey)...) {
+		h.handlerResponse(c, "include deleted", http.StatusForbidden, "only admins can see deleted rows")
+		return false, false
+	}
+
+	return true, true
+}
+
+// isExpanded reports whether relation should be included in the response.
+// Without the expand query byDefault decides, "?expand=" skips everything.
+func (h *Handler) isExpanded(c *gin.Context, relation string, byDefault bool) bool {
+	expand, ok := c.GetQuery("expand")
+	if !ok {
+		return byDefault
+	}
+
+	for _, r := range strings.Split(expand, ",") {
+		if strings.TrimSpace(r) == relation {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/pkg/logger"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout bounds the database check of a readiness probe, so a stuck
+// pool fails the probe instead of hanging it.
+const readyTimeout = 3 * time.Second
+
+// Healthz godoc
+// @ID healthz
+// @Router /healthz [GET]
+// @Summary Liveness
+// @Description Answers as long as the server is running
+// @Tags Health
+// @Produce json
+// @Success 200 {object} models.Health "Alive"
+func (h *Handler) Healthz(c *gin.Context) {
+	// probes are too frequent to go through handlerResponse and its logs
+	c.JSON(http.StatusOK, models.Health{Status: "ok"})
+}
+
+// ShuttingDown makes readiness fail from now on, so no new requests are
+// routed to a server that is about to stop.
+func (h *Handler) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Readyz godoc
+// @ID readyz
+// @Router /readyz [GET]
+// @Summary Readiness
+// @Description Pings the database and reports its migration version and pool stats, fails once the server is shutting down
+// @Tags Health
+// @Produce json
+// @Success 200 {object} models.Health "Ready"
+// @Failure 503 {object} models.Health "Not Ready"
+func (h *Handler) Readyz(c *gin.Context) {
+	if h.shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, models.Health{Status: "shutting down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+
+	database, err := h.storages.Ping(ctx)
+	if err != nil {
+		h.logger.Error("readyz", logger.Error(err))
+		c.JSON(http.StatusServiceUnavailable, models.Health{Status: "unavailable", Error: err.Error()})
+		return
+	}
+
+	if database.MigrationDirty {
+		c.JSON(http.StatusServiceUnavailable, models.Health{
+			Status:   "unavailable",
+			Database: database,
+			Error:    "migration is dirty",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Health{Status: "ok", Database: database})
+}
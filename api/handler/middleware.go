@@ -1 +1,139 @@
 package handler
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authorizationHeader = "Authorization"
+	ctxUserIdKey        = "user_id"
+	ctxSessionIdKey     = "session_id"
+	ctxRolesKey         = "roles"
+	ctxPermissionsKey   = "permissions"
+)
+
+// MetricsMiddleware records every request under the route template it
+// matched, requests that match no route share one series.
+func (h *Handler) MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+
+		h.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// AuthMiddleware checks the bearer access token issued by Login and puts
+// the id of the authenticated user into the request context. Roles and
+// permissions are read from the storage rather than the token, so that
+// assigning or revoking a role takes effect on the next request.
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		bearer := c.GetHeader(authorizationHeader)
+		if len(bearer) <= 0 {
+			h.handlerResponse(c, "auth middleware", http.StatusUnauthorized, "authorization header is required")
+			c.Abort()
+			return
+		}
+
+		token, err := helper.ExtractToken(bearer)
+		if err != nil {
+			h.handlerResponse(c, "auth middleware", http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+
+		info, err := helper.ParseClaims(token, h.cfg.AuthSecretKey)
+		if err != nil {
+			h.handlerResponse(c, "auth middleware", http.StatusUnauthorized, "invalid or expired token")
+			c.Abort()
+			return
+		}
+
+		if len(info.SessionID) <= 0 {
+			h.handlerResponse(c, "auth middleware", http.StatusUnauthorized, "token is not bound to a session, please login again")
+			c.Abort()
+			return
+		}
+
+		session, err := h.storages.Session().GetByID(c.Request.Context(), &models.SessionPrimaryKey{Id: info.SessionID})
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			h.handleError(c, "storage.session.getByID", err)
+			c.Abort()
+			return
+		}
+
+		if session == nil || session.UserId != info.UserID || session.Revoked || session.Expired {
+			h.handlerResponse(c, "auth middleware", http.StatusUnauthorized, "session has been revoked, please login again")
+			c.Abort()
+			return
+		}
+
+		_, err = h.storages.User().GetByID(c.Request.Context(), &models.UserPrimaryKey{Id: info.UserID})
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				h.handlerResponse(c, "storage.user.getByID", http.StatusForbidden, "user of this token does not exist")
+				c.Abort()
+				return
+			}
+			h.handleError(c, "storage.user.getByID", err)
+			c.Abort()
+			return
+		}
+
+		userRoles, err := h.storages.Role().GetUserRoles(c.Request.Context(), &models.UserPrimaryKey{Id: info.UserID})
+		if err != nil {
+			h.handleError(c, "storage.role.getUserRoles", err)
+			c.Abort()
+			return
+		}
+
+		c.Set(ctxUserIdKey, info.UserID)
+		c.Set(ctxSessionIdKey, info.SessionID)
+		c.Set(ctxRolesKey, userRoles.Roles)
+		c.Set(ctxPermissionsKey, userRoles.Permissions)
+
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request only when the user has every given
+// permission. It must run after AuthMiddleware.
+func (h *Handler) RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		for _, permission := range permissions {
+			if !h.hasPermission(c, permission) {
+				h.handlerResponse(c, "permission middleware", http.StatusForbidden, "permission denied: "+permission)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+func (h *Handler) hasPermission(c *gin.Context, permission string) bool {
+	for _, p := range c.GetStringSlice(ctxPermissionsKey) {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
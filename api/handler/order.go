@@ -2,7 +2,10 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
 	"context"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -14,34 +17,78 @@ import (
 // @Summary Create Order
 // @Description Create Order
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param order body models.CreateOrder true "CreateOrderRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 409 {object} Response{data=ErrorResponse} "Insufficient Stock"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateOrder(c *gin.Context) {
 
 	var createOrder models.CreateOrder
 
 	err := c.ShouldBindJSON(&createOrder) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create order", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create order", err)
 		return
 	}
 
-	id, err := h.storages.Order().Create(context.Background(), &createOrder)
+	createOrder.CreatedBy = c.GetString(ctxUserIdKey)
+
+	for _, item := range createOrder.OrderProducts {
+		// clients that do not send a quantity order a single unit
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+	}
+
+	// the order and its lines, with their stock reservations, are created
+	// together or not at all
+	var id string
+	err = h.storages.WithTx(context.Background(), func(tx storage.StorageI) error {
+
+		orderId, err := tx.Order().Create(context.Background(), &createOrder)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range createOrder.OrderProducts {
+			item.OrderId = orderId
+			item.ChangedBy = createOrder.CreatedBy
+
+			_, err = tx.Order().AddOrderProduct(context.Background(), item)
+			if err != nil {
+				return err
+			}
+		}
+
+		id = orderId
+
+		return nil
+	})
 	if err != nil {
-		h.handlerResponse(c, "storage.order.create", http.StatusInternalServerError, err.Error())
+		if errors.Is(err, storage.ErrInsufficientStock) {
+			h.metrics.StockOut()
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.order.create", http.StatusNotFound, "product not found")
+			return
+		}
+		h.handleError(c, "storage.order.create", err)
 		return
 	}
 
 	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.order.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.getByID", err)
 		return
 	}
 
+	h.metrics.OrderCreated(resp.Price)
+
 	c.JSON(http.StatusCreated, resp)
 }
 
@@ -51,36 +98,40 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 // @Summary Get By ID Order
 // @Description Get By ID Order
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param expand query string false "comma separated relations to include: history"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Header 200 {string} ETag "version of the resource"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetByIdOrder(c *gin.Context) {
 	id := c.Param("id")
 
-	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{
+		Id:             id,
+		WithHistory:    h.isExpanded(c, "history", false),
+		IncludeDeleted: includeDeleted,
+	})
 	if err != nil {
-		if err.Error() == "no rows in result set" {
+		if errors.Is(err, storage.ErrNotFound) {
 			h.handlerResponse(c, "storage.order.getByID", http.StatusNotFound, "order not exists")
 			return
 		}
-		h.handlerResponse(c, "storage.order.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.getByID", err)
 		return
 	}
 
-	// for _, p := range resp.OrderItems {
-	// 	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{ProductId: p.ProductId})
-	// 	if err != nil {
-	// 		h.handlerResponse(c, "storage.product.getByID", http.StatusInternalServerError, err.Error())
-	// 		return
-	// 	}
-	// 	p.ProductData = resp
-
-	// }
-
-	h.handlerResponse(c, "get order by id", http.StatusCreated, resp)
+	setETag(c, resp.Version)
+	h.handlerResponse(c, "get order by id", http.StatusOK, resp)
 }
 
 // Get List Order godoc
@@ -89,14 +140,18 @@ func (h *Handler) GetByIdOrder(c *gin.Context) {
 // @Summary Get List Order
 // @Description Get List Order
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
 // @Param search query string false "search"
-// @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Param expand query string false "comma separated relations to include, order_products is included when expand is omitted"
+// @Param cursor query string false "next_cursor of the previous page, empty for the first one; switches to keyset pagination, newest first, without count"
+// @Param include_deleted query bool false "include deleted rows, admins only"
+// @Success 200 {object} Response{data=models.GetListOrderResponse} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetListOrder(c *gin.Context) {
 
 	offset, err := h.getOffsetQuery(c.Query("offset"))
@@ -111,63 +166,192 @@ func (h *Handler) GetListOrder(c *gin.Context) {
 		return
 	}
 
+	cursor, keyset, err := h.getCursorQuery(c)
+	if err != nil {
+		h.handlerResponse(c, "get list order", http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
 	resp, err := h.storages.Order().GetList(context.Background(), &models.GetListOrderRequest{
-		Offset: offset,
-		Limit:  limit,
-		Search: c.Query("search"),
+		Offset:         offset,
+		Limit:          limit,
+		Search:         c.Query("search"),
+		IncludeDeleted: includeDeleted,
+		WithProducts:   h.isExpanded(c, "order_products", true),
+		Cursor:         cursor,
+		Keyset:         keyset,
 	})
 	if err != nil {
-		h.handlerResponse(c, "storage.order.getlist", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.getlist", err)
 		return
 	}
 
 	h.handlerResponse(c, "get list order response", http.StatusOK, resp)
 }
 
+// Get Order History godoc
+// @ID get_order_history
+// @Router /order/{id}/history [GET]
+// @Summary Get Order History
+// @Description Status and price changes of the order, oldest first
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.GetListOrderHistoryResponse} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) GetOrderHistory(c *gin.Context) {
+	id := c.Param("id")
+
+	_, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.order.getByID", http.StatusNotFound, "order not exists")
+			return
+		}
+		h.handleError(c, "storage.order.getByID", err)
+		return
+	}
+
+	resp, err := h.storages.Order().GetHistory(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.order.getHistory", err)
+		return
+	}
+
+	h.handlerResponse(c, "get order history", http.StatusOK, resp)
+}
+
 // Update Order godoc
 // @ID update_order
 // @Router /order/{id} [PUT]
 // @Summary Update Order
 // @Description Update Order
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param order body models.UpdateOrder true "UpdateOrderRequest"
-// @Success 202 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Success 200 {object} models.Order "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) UpdateOrder(c *gin.Context) {
 
 	var updateOrder models.UpdateOrder
 
 	id := c.Param("id")
 
+	version, ok := h.ifMatch(c, "update order")
+	if !ok {
+		return
+	}
+
 	err := c.ShouldBindJSON(&updateOrder)
 	if err != nil {
-		h.handlerResponse(c, "update order", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "update order", err)
 		return
 	}
 
 	updateOrder.Id = id
 
+	updateOrder.Version = version
+
 	rowsAffected, err := h.storages.Order().Update(context.Background(), &updateOrder)
 	if err != nil {
-		h.handlerResponse(c, "storage.order.update", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.update", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.order.update", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.order.getByID", err)
+		return
+	}
+
+	setETag(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// Patch Order godoc
+// @ID patch_order
+// @Router /order/{id} [PATCH]
+// @Summary Patch Order
+// @Description Change only the fields present in a JSON Merge Patch (RFC 7396) document, null clears a field
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
+// @Param order body models.PatchOrder true "PatchOrderRequest"
+// @Success 200 {object} models.Order "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 415 {object} Response{data=ErrorResponse} "Unsupported Media Type"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PatchOrder(c *gin.Context) {
+
+	id := c.Param("id")
+
+	version, ok := h.ifMatch(c, "patch order")
+	if !ok {
+		return
+	}
+
+	current, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.order.getByID", err)
+		return
+	}
+
+	updateOrder := models.UpdateOrder{
+		Id:       current.Id,
+		ClientId: current.ClientId,
+	}
+	patchOrder := models.PatchOrder{Id: id}
+
+	if !h.bindMergePatch(c, "patch order", &updateOrder, &patchOrder) {
+		return
+	}
+
+	patchOrder.Version = version
+
+	rowsAffected, err := h.storages.Order().Patch(context.Background(), &patchOrder)
+	if err != nil {
+		h.handleError(c, "storage.order.patch", err)
 		return
 	}
 
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.order.update", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.order.patch", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.order.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -177,24 +361,32 @@ func (h *Handler) UpdateOrder(c *gin.Context) {
 // @Summary Delete Order
 // @Description Delete Order
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param order body models.OrderPrimaryKey true "DeleteOrderRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteOrder(c *gin.Context) {
 
 	id := c.Param("id")
 
-	rowsAffected, err := h.storages.Order().Delete(context.Background(), &models.OrderPrimaryKey{Id: id})
+	version, ok := h.ifMatch(c, "delete order")
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := h.storages.Order().Delete(context.Background(), &models.OrderPrimaryKey{Id: id, Version: version})
 	if err != nil {
-		h.handlerResponse(c, "storage.order.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order.delete", err)
 		return
 	}
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.order.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.order.delete", storage.ErrNotFound)
 		return
 	}
 
@@ -207,24 +399,52 @@ func (h *Handler) DeleteOrder(c *gin.Context) {
 // @Summary Create Order Item
 // @Description Create Order Item
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param order_item body models.CreateOrderItem true "CreateOrderItemRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 409 {object} Response{data=ErrorResponse} "Insufficient Stock"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateOrderItem(c *gin.Context) {
 
 	var createOrderItem models.CreateOrderItem
 
 	err := c.ShouldBindJSON(&createOrderItem) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create order_item", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create order_item", err)
+		return
+	}
+
+	// order_id is optional only for the lines of a new order
+	if len(createOrderItem.OrderId) <= 0 {
+		h.handlerResponse(c, "create order_item", http.StatusUnprocessableEntity, ErrorResponse{
+			Code:    ErrCodeUnprocessable,
+			Message: "validation failed",
+			Fields:  []FieldError{{Field: "order_id", Code: "required", Message: "is required"}},
+		})
 		return
 	}
+
+	createOrderItem.ChangedBy = c.GetString(ctxUserIdKey)
+
+	// clients that do not send a quantity order a single unit
+	if createOrderItem.Quantity == 0 {
+		createOrderItem.Quantity = 1
+	}
+
 	id, err := h.storages.Order().AddOrderProduct(context.Background(), &createOrderItem)
 	if err != nil {
-		h.handlerResponse(c, "storage.order_item.create", http.StatusInternalServerError, err.Error())
+		if errors.Is(err, storage.ErrInsufficientStock) {
+			h.metrics.StockOut()
+		}
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.order_item.create", http.StatusNotFound, "order or product not found")
+			return
+		}
+		h.handleError(c, "storage.order_item.create", err)
 		return
 	}
 
@@ -239,25 +459,223 @@ func (h *Handler) CreateOrderItem(c *gin.Context) {
 // @Summary Delete Order Item
 // @Description Delete Order Item
 // @Tags Order
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
 // @Param orderItem body models.OrderProductPrimaryKey true "DeleteOrderItemRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 409 {object} Response{data=ErrorResponse} "Order Is Not New"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteOrderItem(c *gin.Context) {
 
 	id := c.Param("id")
 
-	rows, err := h.storages.Order().RemoveOrderItem(context.Background(), &models.OrderProductPrimaryKey{Id: id})
+	rows, err := h.storages.Order().RemoveOrderItem(context.Background(), &models.OrderProductPrimaryKey{
+		Id:        id,
+		ChangedBy: c.GetString(ctxUserIdKey),
+	})
 	if err != nil {
-		h.handlerResponse(c, "storage.order_item.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.order_item.delete", err)
 		return
 	}
 	if rows <= 0 {
-		h.handlerResponse(c, "storage.order.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.order.delete", storage.ErrNotFound)
 		return
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// Confirm Order godoc
+// @ID confirm_order
+// @Router /order/{id}/confirm [POST]
+// @Summary Confirm Order
+// @Description Confirm a new order, its lines can not be changed afterwards
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) ConfirmOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusConfirmed)
+}
+
+// Pay Order godoc
+// @ID pay_order
+// @Router /order/{id}/pay [POST]
+// @Summary Pay Order
+// @Description Mark a confirmed order as paid
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PayOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusPaid)
+}
+
+// Ship Order godoc
+// @ID ship_order
+// @Router /order/{id}/ship [POST]
+// @Summary Ship Order
+// @Description Mark a paid order as shipped
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) ShipOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusShipped)
+}
+
+// Deliver Order godoc
+// @ID deliver_order
+// @Router /order/{id}/deliver [POST]
+// @Summary Deliver Order
+// @Description Mark a shipped order as delivered
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) DeliverOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusDelivered)
+}
+
+// Cancel Order godoc
+// @ID cancel_order
+// @Router /order/{id}/cancel [POST]
+// @Summary Cancel Order
+// @Description Cancel a new or confirmed order and release its stock
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) CancelOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusCancelled)
+}
+
+// Refund Order godoc
+// @ID refund_order
+// @Router /order/{id}/refund [POST]
+// @Summary Refund Order
+// @Description Refund a paid or delivered order
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param transition body models.UpdateOrderStatus false "OrderTransitionRequest"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Invalid Transition"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RefundOrder(c *gin.Context) {
+	h.transitionOrder(c, models.OrderStatusRefunded)
+}
+
+func (h *Handler) transitionOrder(c *gin.Context, status string) {
+
+	var updateOrderStatus models.UpdateOrderStatus
+
+	// the body with a reason is optional
+	err := c.ShouldBindJSON(&updateOrderStatus)
+	if err != nil && !errors.Is(err, io.EOF) {
+		h.handleBindError(c, "transition order", err)
+		return
+	}
+
+	updateOrderStatus.Id = c.Param("id")
+	updateOrderStatus.Status = status
+	updateOrderStatus.ChangedBy = c.GetString(ctxUserIdKey)
+
+	rowsAffected, err := h.storages.Order().UpdateStatus(context.Background(), &updateOrderStatus)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidTransition) {
+			h.handlerResponse(c, "storage.order.updateStatus", http.StatusConflict, ErrorResponse{
+				Code:    ErrCodeInvalidTransition,
+				Message: err.Error() + " to " + status,
+			})
+			return
+		}
+		h.handleError(c, "storage.order.updateStatus", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.order.updateStatus", http.StatusNotFound, "order not exists")
+		return
+	}
+
+	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: updateOrderStatus.Id})
+	if err != nil {
+		h.handleError(c, "storage.order.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "transition order", http.StatusOK, resp)
+}
+
+// Restore Order godoc
+// @ID restore_order
+// @Router /order/{id}/restore [POST]
+// @Summary Restore Order
+// @Description Restore a deleted order, reserving its goods again
+// @Tags Order
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Order} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Insufficient Stock"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RestoreOrder(c *gin.Context) {
+
+	id := c.Param("id")
+
+	rowsAffected, err := h.storages.Order().Restore(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.order.restore", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.order.restore", http.StatusNotFound, "deleted order not found")
+		return
+	}
+
+	resp, err := h.storages.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.order.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "restore order", http.StatusOK, resp)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"app/pkg/helper"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mimeMergePatch = "application/merge-patch+json"
+
+// bindMergePatch applies the JSON Merge Patch of the request body to
+// current, the update request of the resource as it is now, and validates
+// the result like a full update. Only the members the patch touched are
+// copied to patch, whose json fields are the ones that may be patched. It
+// responds itself and returns false when the patch cannot be applied.
+func (h *Handler) bindMergePatch(c *gin.Context, path string, current, patch interface{}) bool {
+	if contentType := c.ContentType(); contentType != mimeMergePatch && contentType != binding.MIMEJSON {
+		h.handlerResponse(c, path, http.StatusUnsupportedMediaType, "content type must be "+mimeMergePatch)
+		return false
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		h.handlerResponse(c, path, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	fields, err := helper.ApplyMergePatch(current, body)
+	if err != nil {
+		h.handlerResponse(c, path, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	patchable := jsonFields(patch)
+
+	var invalid []FieldError
+	for _, field := range fields {
+		if !patchable[field] {
+			invalid = append(invalid, FieldError{Field: field, Code: "read_only", Message: "cannot be patched"})
+		}
+	}
+
+	if len(invalid) > 0 {
+		h.handlerResponse(c, path, http.StatusUnprocessableEntity, ErrorResponse{
+			Code:    ErrCodeUnprocessable,
+			Message: "validation failed",
+			Fields:  invalid,
+		})
+		return false
+	}
+
+	err = binding.Validator.ValidateStruct(current)
+	if err != nil {
+		h.handleBindError(c, path, err)
+		return false
+	}
+
+	// copy the patched members, with the values they have after merging,
+	// so that untouched fields of patch stay nil
+	body, err = json.Marshal(current)
+	if err != nil {
+		h.handleError(c, path, err)
+		return false
+	}
+
+	var merged map[string]json.RawMessage
+	err = json.Unmarshal(body, &merged)
+	if err != nil {
+		h.handleError(c, path, err)
+		return false
+	}
+
+	changed := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		changed[field] = merged[field]
+	}
+
+	body, err = json.Marshal(changed)
+	if err != nil {
+		h.handleError(c, path, err)
+		return false
+	}
+
+	err = json.Unmarshal(body, patch)
+	if err != nil {
+		h.handleError(c, path, err)
+		return false
+	}
+
+	return true
+}
+
+// jsonFields returns the json names of the fields of v, a pointer to a
+// struct, leaving out the ones hidden with "-".
+func jsonFields(v interface{}) map[string]bool {
+	body, _ := json.Marshal(v)
+
+	var fields map[string]json.RawMessage
+	_ = json.Unmarshal(body, &fields)
+
+	names := make(map[string]bool, len(fields))
+	for name := range fields {
+		names[name] = true
+	}
+
+	return names
+}
@@ -2,10 +2,14 @@ package handler
 
 import (
 	"app/api/models"
+	"app/storage"
 	"context"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // Create Product godoc
@@ -14,31 +18,33 @@ import (
 // @Summary Create Product
 // @Description Create Product
 // @Tags Product
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param product body models.CreateProduct true "CreateProductRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateProduct(c *gin.Context) {
 
 	var createProduct models.CreateProduct
 
 	err := c.ShouldBindJSON(&createProduct) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create product", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create product", err)
 		return
 	}
 
 	id, err := h.storages.Product().Create(context.Background(), &createProduct)
 	if err != nil {
-		h.handlerResponse(c, "storage.product.create", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.create", err)
 		return
 	}
 
 	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.product.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.getByID", err)
 		return
 	}
 
@@ -51,21 +57,30 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 // @Summary Get By ID Product
 // @Description Get By ID Product
 // @Tags Product
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Header 200 {string} ETag "version of the resource"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetByIdProduct(c *gin.Context) {
 	id := c.Param("id")
 
-	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id, IncludeDeleted: includeDeleted})
 	if err != nil {
-		h.handlerResponse(c, "storage.product.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -75,14 +90,26 @@ func (h *Handler) GetByIdProduct(c *gin.Context) {
 // @Summary Get List Product
 // @Description Get List Product
 // @Tags Product
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
-// @Param search query string false "search"
-// @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Param search query string false "full text search over name and description, best matches first unless sort_by is given"
+// @Param category_id query []string false "category ids, repeated or comma separated" collectionFormat(multi)
+// @Param include_descendants query bool false "also match products of the subcategories of category_id"
+// @Param min_price query number false "min price"
+// @Param max_price query number false "max price"
+// @Param in_stock query bool false "only products with quantity left"
+// @Param created_from query string false "created at or after, 2006-01-02 or RFC3339"
+// @Param created_to query string false "created at or before, 2006-01-02 (whole day) or RFC3339"
+// @Param sort_by query string false "sort by" Enums(name, price, quantity, created_at)
+// @Param order query string false "sort order" Enums(asc, desc)
+// @Param cursor query string false "next_cursor of the previous page, empty for the first one; switches to keyset pagination, newest first, without count"
+// @Param include_deleted query bool false "include deleted rows, admins only"
+// @Success 200 {object} Response{data=models.GetListProductResponse} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetListProduct(c *gin.Context) {
 
 	offset, err := h.getOffsetQuery(c.Query("offset"))
@@ -97,13 +124,86 @@ func (h *Handler) GetListProduct(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storages.Product().GetList(context.Background(), &models.GetListProductRequest{
-		Offset: offset,
-		Limit:  limit,
-		Search: c.Query("search"),
-	})
+	cursor, keyset, err := h.getCursorQuery(c)
+	if err != nil {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	req := models.GetListProductRequest{
+		Offset:             offset,
+		Limit:              limit,
+		Search:             c.Query("search"),
+		SortBy:             c.Query("sort_by"),
+		Order:              strings.ToLower(c.Query("order")),
+		InStock:            c.Query("in_stock") == "true",
+		IncludeDescendants: c.Query("include_descendants") == "true",
+		IncludeDeleted:     includeDeleted,
+		Cursor:             cursor,
+		Keyset:             keyset,
+	}
+
+	for _, ids := range c.QueryArray("category_id") {
+		for _, id := range strings.Split(ids, ",") {
+			if len(strings.TrimSpace(id)) == 0 {
+				continue
+			}
+			if _, err := uuid.Parse(strings.TrimSpace(id)); err != nil {
+				h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid category_id")
+				return
+			}
+			req.CategoryIds = append(req.CategoryIds, strings.TrimSpace(id))
+		}
+	}
+
+	req.MinPrice, err = h.getFloatQuery(c.Query("min_price"))
 	if err != nil {
-		h.handlerResponse(c, "storage.product.getlist", http.StatusInternalServerError, err.Error())
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid min_price")
+		return
+	}
+
+	req.MaxPrice, err = h.getFloatQuery(c.Query("max_price"))
+	if err != nil {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid max_price")
+		return
+	}
+
+	req.CreatedFrom, err = h.getTimeQuery(c.Query("created_from"), false)
+	if err != nil {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid created_from")
+		return
+	}
+
+	req.CreatedTo, err = h.getTimeQuery(c.Query("created_to"), true)
+	if err != nil {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid created_to")
+		return
+	}
+
+	if len(req.SortBy) > 0 && !h.isOneOf(req.SortBy, models.ProductSortFields...) {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid sort_by")
+		return
+	}
+
+	if len(req.Order) > 0 && !h.isOneOf(req.Order, "asc", "desc") {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "invalid order")
+		return
+	}
+
+	// keyset pages are always ordered by creation time
+	if req.Keyset && (len(req.SortBy) > 0 || len(req.Order) > 0) {
+		h.handlerResponse(c, "get list product", http.StatusBadRequest, "sort_by and order can not be used with cursor")
+		return
+	}
+
+	resp, err := h.storages.Product().GetList(context.Background(), &req)
+	if err != nil {
+		h.handleError(c, "storage.product.getlist", err)
 		return
 	}
 
@@ -116,44 +216,158 @@ func (h *Handler) GetListProduct(c *gin.Context) {
 // @Summary Update Product
 // @Description Update Product
 // @Tags Product
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string true "ETag the resource is expected to have"
 // @Param product body models.UpdateProduct true "UpdateProductRequest"
-// @Success 202 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Success 200 {object} models.Product "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Response 428 {object} Response{data=ErrorResponse} "Precondition Required"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) UpdateProduct(c *gin.Context) {
 
 	var updateProduct models.UpdateProduct
 
 	id := c.Param("id")
 
+	version, ok := h.ifMatch(c, "update product")
+	if !ok {
+		return
+	}
+
+	// a put always writes quantity
+	if !h.requireVersion(c, "update product", version) {
+		return
+	}
+
 	err := c.ShouldBindJSON(&updateProduct)
 	if err != nil {
-		h.handlerResponse(c, "update product", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "update product", err)
 		return
 	}
 
 	updateProduct.Id = id
 
+	current, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.product.getByID", http.StatusNotFound, "product not found")
+			return
+		}
+		h.handleError(c, "storage.product.getByID", err)
+		return
+	}
+
+	if current.Price != updateProduct.Price && !h.hasPermission(c, models.PermissionProductPrice) {
+		h.handlerResponse(c, "update product", http.StatusForbidden, "permission denied: "+models.PermissionProductPrice)
+		return
+	}
+
+	updateProduct.Version = version
+
 	rowsAffected, err := h.storages.Product().Update(context.Background(), &updateProduct)
 	if err != nil {
-		h.handlerResponse(c, "storage.product.update", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.update", err)
 		return
 	}
 
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.product.update", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.product.update", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.product.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.getByID", err)
 		return
 	}
 
+	setETag(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// Patch Product godoc
+// @ID patch_product
+// @Router /product/{id} [PATCH]
+// @Summary Patch Product
+// @Description Change only the fields present in a JSON Merge Patch (RFC 7396) document, null clears a field. Patching quantity needs If-Match.
+// @Tags Product
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
+// @Param product body models.PatchProduct true "PatchProductRequest"
+// @Success 200 {object} models.Product "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 415 {object} Response{data=ErrorResponse} "Unsupported Media Type"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Response 428 {object} Response{data=ErrorResponse} "Precondition Required"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PatchProduct(c *gin.Context) {
+
+	id := c.Param("id")
+
+	version, ok := h.ifMatch(c, "patch product")
+	if !ok {
+		return
+	}
+
+	current, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.product.getByID", err)
+		return
+	}
+
+	updateProduct := models.UpdateProduct{
+		Id:          current.Id,
+		Name:        current.Name,
+		CategoryId:  current.CategoryId,
+		Description: current.Description,
+		Price:       current.Price,
+		Quantity:    current.Quantity,
+	}
+	patchProduct := models.PatchProduct{Id: id}
+
+	if !h.bindMergePatch(c, "patch product", &updateProduct, &patchProduct) {
+		return
+	}
+
+	if patchProduct.Quantity != nil && !h.requireVersion(c, "patch product", version) {
+		return
+	}
+
+	if patchProduct.Price != nil && *patchProduct.Price != current.Price && !h.hasPermission(c, models.PermissionProductPrice) {
+		h.handlerResponse(c, "patch product", http.StatusForbidden, "permission denied: "+models.PermissionProductPrice)
+		return
+	}
+
+	patchProduct.Version = version
+
+	rowsAffected, err := h.storages.Product().Patch(context.Background(), &patchProduct)
+	if err != nil {
+		h.handleError(c, "storage.product.patch", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.product.patch", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.product.getByID", err)
+		return
+	}
+
+	setETag(c, resp.Version)
 	c.JSON(http.StatusOK, resp)
 }
 
@@ -163,25 +377,69 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 // @Summary Delete Product
 // @Description Delete Product
 // @Tags Product
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag the resource is expected to have"
 // @Param product body models.ProductPrimaryKey true "DeleteProductRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 412 {object} Response{data=ErrorResponse} "Precondition Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 
-	rowsAffected, err := h.storages.Product().Delete(context.Background(), &models.ProductPrimaryKey{Id: id})
+	version, ok := h.ifMatch(c, "delete product")
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := h.storages.Product().Delete(context.Background(), &models.ProductPrimaryKey{Id: id, Version: version})
 	if err != nil {
-		h.handlerResponse(c, "storage.product.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.product.delete", err)
 		return
 	}
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.product.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.product.delete", storage.ErrNotFound)
 		return
 	}
 
 	h.handlerResponse(c, "delete product", http.StatusNoContent, nil)
 }
+
+// Restore Product godoc
+// @ID restore_product
+// @Router /product/{id}/restore [POST]
+// @Summary Restore Product
+// @Description Restore a deleted product
+// @Tags Product
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.Product} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RestoreProduct(c *gin.Context) {
+
+	id := c.Param("id")
+
+	rowsAffected, err := h.storages.Product().Restore(context.Background(), &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.product.restore", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.product.restore", http.StatusNotFound, "deleted product not found")
+		return
+	}
+
+	resp, err := h.storages.Product().GetByID(context.Background(), &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.product.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "restore product", http.StatusOK, resp)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Get List Role godoc
+// @ID get_list_role
+// @Router /role [GET]
+// @Summary Get List Role
+// @Description Get List Role
+// @Tags Role
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param offset query string false "offset"
+// @Param limit query string false "limit"
+// @Param search query string false "search"
+// @Success 200 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) GetListRole(c *gin.Context) {
+
+	offset, err := h.getOffsetQuery(c.Query("offset"))
+	if err != nil {
+		h.handlerResponse(c, "get list role", http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	limit, err := h.getLimitQuery(c.Query("limit"))
+	if err != nil {
+		h.handlerResponse(c, "get list role", http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	resp, err := h.storages.Role().GetList(context.Background(), &models.GetListRoleRequest{
+		Offset: offset,
+		Limit:  limit,
+		Search: c.Query("search"),
+	})
+	if err != nil {
+		h.handleError(c, "storage.role.getlist", err)
+		return
+	}
+
+	h.handlerResponse(c, "get list role response", http.StatusOK, resp)
+}
+
+// Get User Roles godoc
+// @ID get_user_roles
+// @Router /user/{id}/roles [GET]
+// @Summary Get User Roles
+// @Description Get roles and permissions of the user
+// @Tags Role
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.UserRoles} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) GetUserRoles(c *gin.Context) {
+
+	id := c.Param("id")
+
+	resp, err := h.storages.Role().GetUserRoles(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.role.getUserRoles", err)
+		return
+	}
+
+	h.handlerResponse(c, "get user roles", http.StatusOK, resp)
+}
+
+// Assign Role godoc
+// @ID assign_role
+// @Router /user/{id}/roles [POST]
+// @Summary Assign Role
+// @Description Assign a role to the user, takes effect on the next request
+// @Tags Role
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param role body models.UserRole true "AssignRoleRequest"
+// @Success 201 {object} Response{data=models.UserRoles} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) AssignRole(c *gin.Context) {
+
+	var userRole models.UserRole
+
+	err := c.ShouldBindJSON(&userRole) // parse req body to given type struct
+	if err != nil {
+		h.handlerResponse(c, "assign role", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userRole.UserId = c.Param("id")
+
+	_, err = h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: userRole.UserId})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.user.getByID", http.StatusNotFound, "user not found")
+			return
+		}
+		h.handleError(c, "storage.user.getByID", err)
+		return
+	}
+
+	_, err = h.storages.Role().GetByID(context.Background(), &models.RolePrimaryKey{Name: userRole.Role})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			h.handlerResponse(c, "storage.role.getByID", http.StatusNotFound, "role not found")
+			return
+		}
+		h.handleError(c, "storage.role.getByID", err)
+		return
+	}
+
+	_, err = h.storages.Role().AssignRole(context.Background(), &userRole)
+	if err != nil {
+		h.handleError(c, "storage.role.assignRole", err)
+		return
+	}
+
+	resp, err := h.storages.Role().GetUserRoles(context.Background(), &models.UserPrimaryKey{Id: userRole.UserId})
+	if err != nil {
+		h.handleError(c, "storage.role.getUserRoles", err)
+		return
+	}
+
+	h.handlerResponse(c, "assign role", http.StatusCreated, resp)
+}
+
+// Revoke Role godoc
+// @ID revoke_role
+// @Router /user/{id}/roles/{role} [DELETE]
+// @Summary Revoke Role
+// @Description Revoke a role from the user
+// @Tags Role
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param role path string true "role"
+// @Success 204 {object} Response{data=string} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RevokeRole(c *gin.Context) {
+
+	rowsAffected, err := h.storages.Role().RevokeRole(context.Background(), &models.UserRole{
+		UserId: c.Param("id"),
+		Role:   c.Param("role"),
+	})
+	if err != nil {
+		h.handleError(c, "storage.role.revokeRole", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.role.revokeRole", storage.ErrNotFound)
+		return
+	}
+
+	h.handlerResponse(c, "revoke role", http.StatusNoContent, nil)
+}
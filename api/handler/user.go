@@ -2,6 +2,8 @@ package handler
 
 import (
 	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
 	"context"
 	"net/http"
 
@@ -14,31 +16,39 @@ import (
 // @Summary Create User
 // @Description Create User
 // @Tags User
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param user body models.CreateUser true "CreateUserRequest"
 // @Success 201 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) CreateUser(c *gin.Context) {
 
 	var createUser models.CreateUser
 
 	err := c.ShouldBindJSON(&createUser) // parse req body to given type struct
 	if err != nil {
-		h.handlerResponse(c, "create user", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "create user", err)
+		return
+	}
+
+	createUser.Password, err = helper.HashPassword(createUser.Password)
+	if err != nil {
+		h.handleError(c, "helper.hashPassword", err)
 		return
 	}
 
 	id, err := h.storages.User().Create(context.Background(), &createUser)
 	if err != nil {
-		h.handlerResponse(c, "storage.user.create", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.create", err)
 		return
 	}
 
 	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.getByID", err)
 		return
 	}
 
@@ -51,19 +61,26 @@ func (h *Handler) CreateUser(c *gin.Context) {
 // @Summary Get By ID User
 // @Description Get By ID User
 // @Tags User
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetByIdUser(c *gin.Context) {
 
 	id := c.Param("id")
 
-	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id, IncludeDeleted: includeDeleted})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.getByID", err)
 		return
 	}
 
@@ -76,14 +93,16 @@ func (h *Handler) GetByIdUser(c *gin.Context) {
 // @Summary Get List User
 // @Description Get List User
 // @Tags User
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param offset query string false "offset"
 // @Param limit query string false "limit"
 // @Param search query string false "search"
+// @Param include_deleted query bool false "include deleted rows, admins only"
 // @Success 200 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) GetListUser(c *gin.Context) {
 
 	offset, err := h.getOffsetQuery(c.Query("offset"))
@@ -98,13 +117,19 @@ func (h *Handler) GetListUser(c *gin.Context) {
 		return
 	}
 
+	includeDeleted, ok := h.includeDeleted(c)
+	if !ok {
+		return
+	}
+
 	resp, err := h.storages.User().GetList(context.Background(), &models.GetListUserRequest{
-		Offset: offset,
-		Limit:  limit,
-		Search: c.Query("search"),
+		Offset:         offset,
+		Limit:          limit,
+		Search:         c.Query("search"),
+		IncludeDeleted: includeDeleted,
 	})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getlist", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.getlist", err)
 		return
 	}
 
@@ -117,13 +142,15 @@ func (h *Handler) GetListUser(c *gin.Context) {
 // @Summary Update User
 // @Description Update User
 // @Tags User
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
 // @Param user body models.UpdateUser true "UpdateUserRequest"
-// @Success 202 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Success 200 {object} Response{data=models.User} "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) UpdateUser(c *gin.Context) {
 
 	var updateUser models.UpdateUser
@@ -132,30 +159,116 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&updateUser)
 	if err != nil {
-		h.handlerResponse(c, "update user", http.StatusBadRequest, err.Error())
+		h.handleBindError(c, "update user", err)
 		return
 	}
 
 	updateUser.Id = id
 
+	if len(updateUser.Password) > 0 {
+		updateUser.Password, err = helper.HashPassword(updateUser.Password)
+		if err != nil {
+			h.handleError(c, "helper.hashPassword", err)
+			return
+		}
+	}
+
 	rowsAffected, err := h.storages.User().Update(context.Background(), &updateUser)
 	if err != nil {
-		h.handlerResponse(c, "storage.user.update", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.update", err)
+		return
+	}
+
+	if rowsAffected <= 0 {
+		h.handleError(c, "storage.user.update", storage.ErrNotFound)
+		return
+	}
+
+	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.user.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "update user", http.StatusOK, resp)
+}
+
+// Patch User godoc
+// @ID patch_user
+// @Router /user/{id} [PATCH]
+// @Summary Patch User
+// @Description Change only the fields present in a JSON Merge Patch (RFC 7396) document, null clears a field
+// @Tags User
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Param user body models.PatchUser true "PatchUserRequest"
+// @Success 200 {object} models.User "Success Request"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 415 {object} Response{data=ErrorResponse} "Unsupported Media Type"
+// @Response 422 {object} Response{data=ErrorResponse} "Validation Failed"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) PatchUser(c *gin.Context) {
+
+	id := c.Param("id")
+
+	current, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.user.getByID", err)
+		return
+	}
+
+	updateUser := models.UpdateUser{
+		Id:          current.Id,
+		FirstName:   current.FirstName,
+		LastName:    current.LastName,
+		Login:       current.Login,
+		PhoneNumber: current.PhoneNumber,
+	}
+	patchUser := models.PatchUser{Id: id}
+
+	if !h.bindMergePatch(c, "patch user", &updateUser, &patchUser) {
+		return
+	}
+
+	if patchUser.Password != nil {
+		if len(*patchUser.Password) <= 0 {
+			h.handlerResponse(c, "patch user", http.StatusUnprocessableEntity, ErrorResponse{
+				Code:    ErrCodeUnprocessable,
+				Message: "validation failed",
+				Fields:  []FieldError{{Field: "password", Code: "required", Message: "is required"}},
+			})
+			return
+		}
+
+		hashed, err := helper.HashPassword(*patchUser.Password)
+		if err != nil {
+			h.handleError(c, "helper.hashPassword", err)
+			return
+		}
+		patchUser.Password = &hashed
+	}
+
+	rowsAffected, err := h.storages.User().Patch(context.Background(), &patchUser)
+	if err != nil {
+		h.handleError(c, "storage.user.patch", err)
 		return
 	}
 
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.user.update", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.user.patch", storage.ErrNotFound)
 		return
 	}
 
 	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.getByID", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.getByID", err)
 		return
 	}
 
-	h.handlerResponse(c, "update user", http.StatusAccepted, resp)
+	c.JSON(http.StatusOK, resp)
 }
 
 // DELETE User godoc
@@ -164,26 +277,64 @@ func (h *Handler) UpdateUser(c *gin.Context) {
 // @Summary Delete User
 // @Description Delete User
 // @Tags User
+// @Security ApiKeyAuth
 // @Accept json
 // @Produce json
 // @Param id path string true "id"
 // @Param user body models.UserPrimaryKey true "DeleteUserRequest"
 // @Success 204 {object} Response{data=string} "Success Request"
-// @Response 400 {object} Response{data=string} "Bad Request"
-// @Failure 500 {object} Response{data=string} "Server Error"
+// @Response 400 {object} Response{data=ErrorResponse} "Bad Request"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
 func (h *Handler) DeleteUser(c *gin.Context) {
 
 	id := c.Param("id")
 
 	rowsAffected, err := h.storages.User().Delete(context.Background(), &models.UserPrimaryKey{Id: id})
 	if err != nil {
-		h.handlerResponse(c, "storage.user.delete", http.StatusInternalServerError, err.Error())
+		h.handleError(c, "storage.user.delete", err)
 		return
 	}
 	if rowsAffected <= 0 {
-		h.handlerResponse(c, "storage.user.delete", http.StatusBadRequest, "now rows affected")
+		h.handleError(c, "storage.user.delete", storage.ErrNotFound)
 		return
 	}
 
 	h.handlerResponse(c, "delete user", http.StatusNoContent, nil)
 }
+
+// Restore User godoc
+// @ID restore_user
+// @Router /user/{id}/restore [POST]
+// @Summary Restore User
+// @Description Restore a deleted user
+// @Tags User
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "id"
+// @Success 200 {object} Response{data=models.User} "Success Request"
+// @Response 404 {object} Response{data=ErrorResponse} "Not Found"
+// @Response 409 {object} Response{data=ErrorResponse} "Login Taken"
+// @Failure 500 {object} Response{data=ErrorResponse} "Server Error"
+func (h *Handler) RestoreUser(c *gin.Context) {
+
+	id := c.Param("id")
+
+	rowsAffected, err := h.storages.User().Restore(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.user.restore", err)
+		return
+	}
+	if rowsAffected <= 0 {
+		h.handlerResponse(c, "storage.user.restore", http.StatusNotFound, "deleted user not found")
+		return
+	}
+
+	resp, err := h.storages.User().GetByID(context.Background(), &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		h.handleError(c, "storage.user.getByID", err)
+		return
+	}
+
+	h.handlerResponse(c, "restore user", http.StatusOK, resp)
+}
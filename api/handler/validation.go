@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"app/pkg/helper"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterValidators adds the custom binding rules ("uzphone", "login") to
+// gin's validator and makes it report fields by their json names. It has to
+// run once before the first request is bound.
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected binding validator engine")
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	err := v.RegisterValidation("uzphone", func(fl validator.FieldLevel) bool {
+		return helper.IsValidPhone(fl.Field().String())
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.RegisterValidation("login", func(fl validator.FieldLevel) bool {
+		return helper.IsValidLogin(fl.Field().String())
+	})
+}
+
+// handleBindError responds to a request body that could not be bound: a
+// 422 listing every invalid field when validation failed, a 400 when the
+// body is not valid json.
+func (h *Handler) handleBindError(c *gin.Context, path string, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		h.handlerResponse(c, path, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.handlerResponse(c, path, http.StatusUnprocessableEntity, ErrorResponse{
+		Code:    ErrCodeUnprocessable,
+		Message: "validation failed",
+		Fields:  fieldErrors(validationErrs),
+	})
+}
+
+func fieldErrors(errs validator.ValidationErrors) []FieldError {
+	fields := make([]FieldError, 0, len(errs))
+	for _, e := range errs {
+		fields = append(fields, FieldError{
+			Field:   fieldPath(e.Namespace()),
+			Code:    e.Tag(),
+			Message: fieldMessage(e),
+		})
+	}
+	return fields
+}
+
+// fieldPath drops the struct name from a namespace such as
+// "CreateOrder.order_products[0].product_id".
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "uuid":
+		return "must be a valid uuid"
+	case "uzphone":
+		return "must be a phone number in the +998XXXXXXXXX format"
+	case "login":
+		return "must start with a letter and contain 6 to 30 letters, digits or underscores"
+	case "min":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters long", e.Param())
+		}
+		return "must be at least " + e.Param()
+	case "max":
+		if e.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters long", e.Param())
+		}
+		return "must be at most " + e.Param()
+	case "gte":
+		return "must be greater than or equal to " + e.Param()
+	default:
+		return "is invalid"
+	}
+}
@@ -1,31 +1,54 @@
 package models
 
 type Category struct {
-	Id        string `json:"id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	Id        string      `json:"id"`
+	ParentId  string      `json:"parent_id"`
+	Name      string      `json:"name"`
+	CreatedAt string      `json:"created_at"`
+	UpdatedAt string      `json:"updated_at"`
+	Version   int         `json:"version,omitempty"`
+	DeletedAt string      `json:"deleted_at,omitempty"`
+	Children  []*Category `json:"children,omitempty"`
 }
 type CategoryPrimaryKey struct {
-	Id string `json:"id"`
+	Id             string `json:"id"`
+	IncludeDeleted bool   `json:"-"`
+	Version        int    `json:"-"`
 }
 
 type CreateCategory struct {
-	Name string `json:"name"`
+	ParentId string `json:"parent_id" binding:"omitempty,uuid"`
+	Name     string `json:"name" binding:"required,max=255"`
 }
 
 type UpdateCategory struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id       string `json:"id"`
+	ParentId string `json:"parent_id" binding:"omitempty,uuid"`
+	Name     string `json:"name" binding:"required,max=255"`
+	Version  int    `json:"-"`
+}
+
+// PatchCategory changes only the fields that are not nil, an empty
+// ParentId makes the category a root.
+type PatchCategory struct {
+	Id       string  `json:"-"`
+	ParentId *string `json:"parent_id"`
+	Name     *string `json:"name"`
+	Version  int     `json:"-"`
 }
 
 type GetListCategoryRequest struct {
-	Offset int    `json:"offset"`
-	Limit  int    `json:"limit"`
-	Search string `json:"search"`
+	Offset         int    `json:"offset"`
+	Limit          int    `json:"limit"`
+	Search         string `json:"search"`
+	IncludeDeleted bool   `json:"-"`
 }
 
 type GetListCategoryResponse struct {
 	Count      int         `json:"count"`
 	Categories []*Category `json:"categories"`
 }
+
+type GetCategoryTreeResponse struct {
+	Categories []*Category `json:"categories"`
+}
@@ -1,38 +1,58 @@
 package models
 
 type Client struct {
-	Id          string `json:"id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
-	CreatedAt   string `json:"created_at"`
-	UpdatedAt   string `json:"updated_at"`
+	Id          string  `json:"id"`
+	FirstName   string  `json:"first_name"`
+	LastName    string  `json:"last_name"`
+	PhoneNumber string  `json:"phone_number"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at"`
+	Version     int     `json:"version,omitempty"`
+	DeletedAt   string  `json:"deleted_at,omitempty"`
+	Rank        float64 `json:"rank,omitempty"`
+	Highlight   string  `json:"highlight,omitempty"`
 }
 
 type ClientPrimaryKey struct {
-	Id string `json:"id"`
+	Id             string `json:"id"`
+	IncludeDeleted bool   `json:"-"`
+	Version        int    `json:"-"`
 }
 
 type CreateClient struct {
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name" binding:"required,max=255"`
+	LastName    string `json:"last_name" binding:"required,max=255"`
+	PhoneNumber string `json:"phone_number" binding:"required,uzphone"`
 }
 
 type UpdateClient struct {
 	Id          string `json:"id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name" binding:"required,max=255"`
+	LastName    string `json:"last_name" binding:"required,max=255"`
+	PhoneNumber string `json:"phone_number" binding:"required,uzphone"`
+	Version     int    `json:"-"`
+}
+
+// PatchClient changes only the fields that are not nil.
+type PatchClient struct {
+	Id          string  `json:"-"`
+	FirstName   *string `json:"first_name"`
+	LastName    *string `json:"last_name"`
+	PhoneNumber *string `json:"phone_number"`
+	Version     int     `json:"-"`
 }
 
 type GetListClientRequest struct {
-	Offset int    `json:"offset"`
-	Limit  int    `json:"limit"`
-	Search string `json:"search"`
+	Offset         int    `json:"offset"`
+	Limit          int    `json:"limit"`
+	Search         string `json:"search"`
+	Cursor         string `json:"cursor"`
+	Keyset         bool   `json:"-"`
+	IncludeDeleted bool   `json:"-"`
 }
 
 type GetListClientResponse struct {
-	Count   int       `json:"count"`
-	Clients []*Client `json:"clients"`
+	Count      int       `json:"count"`
+	Clients    []*Client `json:"clients"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,28 @@
+package models
+
+// Health is the body of the liveness and readiness probes.
+type Health struct {
+	Status   string          `json:"status"`
+	Database *DatabaseHealth `json:"database,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// DatabaseHealth is the state of the database behind the storage. Pool is
+// nil for storages without a connection pool.
+type DatabaseHealth struct {
+	MigrationVersion int        `json:"migration_version"`
+	MigrationDirty   bool       `json:"migration_dirty"`
+	Pool             *PoolStats `json:"pool,omitempty"`
+}
+
+type PoolStats struct {
+	MaxConns             int32  `json:"max_conns"`
+	TotalConns           int32  `json:"total_conns"`
+	AcquiredConns        int32  `json:"acquired_conns"`
+	IdleConns            int32  `json:"idle_conns"`
+	ConstructingConns    int32  `json:"constructing_conns"`
+	AcquireCount         int64  `json:"acquire_count"`
+	EmptyAcquireCount    int64  `json:"empty_acquire_count"`
+	CanceledAcquireCount int64  `json:"canceled_acquire_count"`
+	AcquireDuration      string `json:"acquire_duration"`
+}
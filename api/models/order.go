@@ -1,5 +1,47 @@
 package models
 
+const (
+	OrderStatusNew       = "new"
+	OrderStatusConfirmed = "confirmed"
+	OrderStatusPaid      = "paid"
+	OrderStatusShipped   = "shipped"
+	OrderStatusDelivered = "delivered"
+	OrderStatusCancelled = "cancelled"
+	OrderStatusRefunded  = "refunded"
+)
+
+// OrderStatusTransitions lists the statuses an order may move to from each
+// status, cancelled and refunded are final.
+var OrderStatusTransitions = map[string][]string{
+	OrderStatusNew:       {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed: {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, status := range OrderStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OrderHoldsStock tells whether the goods of an order in status are still
+// reserved: shipped goods are gone and cancelled or refunded orders have
+// released them.
+func OrderHoldsStock(status string) bool {
+	switch status {
+	case OrderStatusNew, OrderStatusConfirmed, OrderStatusPaid:
+		return true
+	}
+
+	return false
+}
+
 type Order struct {
 	Id            string          `json:"id"`
 	ClientId      string          `json:"client_id"`
@@ -8,53 +50,112 @@ type Order struct {
 	Status        string          `json:"status"`
 	CreatedAt     string          `json:"created_at"`
 	UpdatedAt     string          `json:"updated_at"`
+	Version       int             `json:"version,omitempty"`
+	DeletedAt     string          `json:"deleted_at,omitempty"`
 	OrderProducts []*OrderProduct `json:"order_products"`
+	History       []*OrderHistory `json:"history,omitempty"`
 }
 
 type OrderPrimaryKey struct {
-	Id string `json:"id"`
+	Id             string `json:"id"`
+	WithHistory    bool   `json:"-"`
+	IncludeDeleted bool   `json:"-"`
+	Version        int    `json:"-"`
 }
 
 type CreateOrder struct {
-	ClientId  string  `json:"client_id"`
-	Price     float64 `json:"price"`
-	Status    string  `json:"status"`
-	CreatedAt string  `json:"created_at"`
-	UpdatedAt string  `json:"updated_at"`
+	ClientId      string             `json:"client_id" binding:"required,uuid"`
+	OrderProducts []*CreateOrderItem `json:"order_products" binding:"dive"`
+	CreatedBy     string             `json:"-"`
+	CreatedAt     string             `json:"created_at"`
+	UpdatedAt     string             `json:"updated_at"`
 }
 
 type UpdateOrder struct {
-	Id        string  `json:"id"`
-	ClientId  string  `json:"client_id"`
-	Price     float64 `json:"price"`
-	Status    string  `json:"status"`
-	UpdatedAt string  `json:"updated_at"`
+	Id        string `json:"id"`
+	ClientId  string `json:"client_id" binding:"required,uuid"`
+	UpdatedAt string `json:"updated_at"`
+	Version   int    `json:"-"`
+}
+
+// PatchOrder changes only the fields that are not nil.
+type PatchOrder struct {
+	Id       string  `json:"-"`
+	ClientId *string `json:"client_id"`
+	Version  int     `json:"-"`
+}
+
+type UpdateOrderStatus struct {
+	Id        string `json:"-"`
+	Status    string `json:"-"`
+	Reason    string `json:"reason" binding:"max=500"`
+	ChangedBy string `json:"-"`
 }
 
 type GetListOrderRequest struct {
-	Offset int    `json:"offset"`
-	Limit  int    `json:"limit"`
-	Search string `json:"search"`
+	Offset         int    `json:"offset"`
+	Limit          int    `json:"limit"`
+	Search         string `json:"search"`
+	WithProducts   bool   `json:"with_products"`
+	Cursor         string `json:"cursor"`
+	Keyset         bool   `json:"-"`
+	IncludeDeleted bool   `json:"-"`
 }
 
 type GetListOrderResponse struct {
-	Count  int      `json:"count"`
-	Orders []*Order `json:"orders"`
+	Count      int      `json:"count"`
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
 }
 
 // -----------------------ITEM------------------
 type OrderProduct struct {
 	Id          string   `json:"id"`
-	OrderId     int      `json:"order_id"`
-	ProductId   int      `json:"product_id"`
+	OrderId     string   `json:"order_id"`
+	ProductId   string   `json:"product_id"`
 	ProductData *Product `json:"product_data"`
+	Quantity    int      `json:"quantity"`
+	UnitPrice   float64  `json:"unit_price"`
+	TotalPrice  float64  `json:"total_price"`
+	CreatedAt   string   `json:"created_at"`
 }
 
 type OrderProductPrimaryKey struct {
-	Id string `json:"id"`
+	Id        string `json:"id"`
+	ChangedBy string `json:"-"`
 }
 
 type CreateOrderItem struct {
-	OrderId   string `json:"order_id"`
-	ProductId string `json:"product_id"`
+	OrderId   string `json:"order_id" binding:"omitempty,uuid"`
+	ProductId string `json:"product_id" binding:"required,uuid"`
+	Quantity  int    `json:"quantity" binding:"gte=0"`
+	ChangedBy string `json:"-"`
+}
+
+// -----------------------HISTORY------------------
+type OrderHistory struct {
+	Id         string  `json:"id"`
+	OrderId    string  `json:"order_id"`
+	FromStatus string  `json:"from_status"`
+	ToStatus   string  `json:"to_status"`
+	FromPrice  float64 `json:"from_price"`
+	ToPrice    float64 `json:"to_price"`
+	ChangedBy  string  `json:"changed_by"`
+	Reason     string  `json:"reason"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+type CreateOrderHistory struct {
+	OrderId    string
+	FromStatus string
+	ToStatus   string
+	FromPrice  float64
+	ToPrice    float64
+	ChangedBy  string
+	Reason     string
+}
+
+type GetListOrderHistoryResponse struct {
+	Count   int             `json:"count"`
+	History []*OrderHistory `json:"history"`
 }
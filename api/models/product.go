@@ -10,38 +10,72 @@ type Product struct {
 	Quantity     int       `json:"quantity"`
 	CreatedAt    string    `json:"created_at"`
 	UpdatedAt    string    `json:"updated_at"`
+	Version      int       `json:"version,omitempty"`
+	DeletedAt    string    `json:"deleted_at,omitempty"`
+	Rank         float64   `json:"rank,omitempty"`
+	Highlight    string    `json:"highlight,omitempty"`
 }
 type ProductPrimaryKey struct {
-	Id string `json:"id"`
+	Id             string `json:"id"`
+	IncludeDeleted bool   `json:"-"`
+	Version        int    `json:"-"`
 }
 
 type CreateProduct struct {
-	Name        string  `json:"name"`
-	CategoryId  string  `json:"category_id"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	Name        string  `json:"name" binding:"required,max=255"`
+	CategoryId  string  `json:"category_id" binding:"required,uuid"`
+	Description string  `json:"description" binding:"max=2000"`
+	Price       float64 `json:"price" binding:"gte=0"`
+	Quantity    int     `json:"quantity" binding:"gte=0"`
 	CreatedAt   string  `json:"created_at"`
 	UpdatedAt   string  `json:"updated_at"`
 }
 
 type UpdateProduct struct {
 	Id          string  `json:"id"`
-	Name        string  `json:"name"`
-	CategoryId  string  `json:"category_id"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	Name        string  `json:"name" binding:"required,max=255"`
+	CategoryId  string  `json:"category_id" binding:"required,uuid"`
+	Description string  `json:"description" binding:"max=2000"`
+	Price       float64 `json:"price" binding:"gte=0"`
+	Quantity    int     `json:"quantity" binding:"gte=0"`
 	UpdatedAt   string  `json:"updated_at"`
+	Version     int     `json:"-"`
+}
+
+// PatchProduct changes only the fields that are not nil.
+type PatchProduct struct {
+	Id          string   `json:"-"`
+	Name        *string  `json:"name"`
+	CategoryId  *string  `json:"category_id"`
+	Description *string  `json:"description"`
+	Price       *float64 `json:"price"`
+	Quantity    *int     `json:"quantity"`
+	Version     int      `json:"-"`
 }
 
 type GetListProductRequest struct {
-	Offset int    `json:"offset"`
-	Limit  int    `json:"limit"`
-	Search string `json:"search"`
+	Offset             int      `json:"offset"`
+	Limit              int      `json:"limit"`
+	Search             string   `json:"search"`
+	CategoryIds        []string `json:"category_ids"`
+	IncludeDescendants bool     `json:"include_descendants"`
+	MinPrice           *float64 `json:"min_price"`
+	MaxPrice           *float64 `json:"max_price"`
+	InStock            bool     `json:"in_stock"`
+	CreatedFrom        string   `json:"created_from"`
+	CreatedTo          string   `json:"created_to"`
+	SortBy             string   `json:"sort_by"`
+	Order              string   `json:"order"`
+	Cursor             string   `json:"cursor"`
+	Keyset             bool     `json:"-"`
+	IncludeDeleted     bool     `json:"-"`
 }
 
+// ProductSortFields lists the fields products can be sorted by.
+var ProductSortFields = []string{"name", "price", "quantity", "created_at"}
+
 type GetListProductResponse struct {
-	Count    int        `json:"count"`
-	Products []*Product `json:"products"`
+	Count      int        `json:"count"`
+	Products   []*Product `json:"products"`
+	NextCursor string     `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,7 @@
+package models
+
+// PurgeRequest selects the soft deleted rows old enough to be removed for
+// good.
+type PurgeRequest struct {
+	Days int `json:"days"`
+}
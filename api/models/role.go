@@ -0,0 +1,60 @@
+package models
+
+const (
+	RoleAdmin   = "admin"
+	RoleManager = "manager"
+	RoleCashier = "cashier"
+)
+
+const (
+	PermissionUserRead      = "user:read"
+	PermissionUserWrite     = "user:write"
+	PermissionUserDelete    = "user:delete"
+	PermissionRoleRead      = "role:read"
+	PermissionRoleAssign    = "role:assign"
+	PermissionCategoryWrite = "category:write"
+	PermissionProductWrite  = "product:write"
+	PermissionProductPrice  = "product:price"
+	PermissionProductDelete = "product:delete"
+	PermissionClientWrite   = "client:write"
+	PermissionClientDelete  = "client:delete"
+	PermissionOrderCreate   = "order:create"
+	PermissionOrderWrite    = "order:write"
+	PermissionOrderDelete   = "order:delete"
+)
+
+type Role struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type RolePrimaryKey struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type GetListRoleRequest struct {
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Search string `json:"search"`
+}
+
+type GetListRoleResponse struct {
+	Count int     `json:"count"`
+	Roles []*Role `json:"roles"`
+}
+
+type UserRole struct {
+	UserId string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type UserRoles struct {
+	UserId      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+type Session struct {
+	Id         string `json:"id"`
+	UserId     string `json:"user_id"`
+	FamilyId   string `json:"family_id"`
+	UserAgent  string `json:"user_agent"`
+	IpAddress  string `json:"ip_address"`
+	ExpiresAt  string `json:"expires_at"`
+	RevokedAt  string `json:"revoked_at"`
+	ReplacedBy string `json:"replaced_by"`
+	Expired    bool   `json:"expired"`
+	Revoked    bool   `json:"revoked"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type SessionPrimaryKey struct {
+	Id               string `json:"id"`
+	RefreshTokenHash string `json:"-"`
+}
+
+type CreateSession struct {
+	UserId           string
+	FamilyId         string
+	RefreshTokenHash string
+	UserAgent        string
+	IpAddress        string
+	TTL              time.Duration
+}
+
+type RevokeSession struct {
+	Id         string
+	FamilyId   string
+	UserId     string
+	ReplacedBy string
+}
@@ -5,38 +5,63 @@ type User struct {
 	FirstName   string `json:"first_name"`
 	LastName    string `json:"last_name"`
 	Login       string `json:"login"`
-	Password    string `json:"password"`
 	PhoneNumber string `json:"phone_number"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
+	DeletedAt   string `json:"deleted_at,omitempty"`
 }
 
 type UserPrimaryKey struct {
-	Id    string `json:"id"`
-	Login string `json:"login"`
+	Id             string `json:"id"`
+	Login          string `json:"login"`
+	IncludeDeleted bool   `json:"-"`
+}
+
+// UserCredentials is used only for authentication and is never returned
+// to clients.
+type UserCredentials struct {
+	Id       string
+	Login    string
+	Password string
+}
+
+type UpdateUserPassword struct {
+	Id       string
+	Password string
 }
 
 type CreateUser struct {
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Login       string `json:"login"`
-	Password    string `json:"password"`
-	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name" binding:"required,max=255"`
+	LastName    string `json:"last_name" binding:"required,max=255"`
+	Login       string `json:"login" binding:"required,login"`
+	Password    string `json:"password" binding:"required,min=6,max=72"`
+	PhoneNumber string `json:"phone_number" binding:"required,uzphone"`
 }
 
 type UpdateUser struct {
 	Id          string `json:"id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Login       string `json:"login"`
-	Password    string `json:"password"`
-	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name" binding:"required,max=255"`
+	LastName    string `json:"last_name" binding:"required,max=255"`
+	Login       string `json:"login" binding:"required,login"`
+	Password    string `json:"password" binding:"omitempty,min=6,max=72"`
+	PhoneNumber string `json:"phone_number" binding:"required,uzphone"`
+}
+
+// PatchUser changes only the fields that are not nil.
+type PatchUser struct {
+	Id          string  `json:"-"`
+	FirstName   *string `json:"first_name"`
+	LastName    *string `json:"last_name"`
+	Login       *string `json:"login"`
+	Password    *string `json:"password"`
+	PhoneNumber *string `json:"phone_number"`
 }
 
 type GetListUserRequest struct {
-	Offset int    `json:"offset"`
-	Limit  int    `json:"limit"`
-	Search string `json:"search"`
+	Offset         int    `json:"offset"`
+	Limit          int    `json:"limit"`
+	Search         string `json:"search"`
+	IncludeDeleted bool   `json:"-"`
 }
 
 type GetListUserResponse struct {
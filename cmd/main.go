@@ -2,12 +2,29 @@ package main
 
 import (
 	"app/api"
+	"app/api/models"
 	"app/config"
+	"app/migrations"
+	"app/pkg/helper"
 	"app/pkg/logger"
+	"app/pkg/metrics"
+	"app/pkg/migrate"
+	"app/storage"
 	"app/storage/postgresql"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
@@ -39,24 +56,292 @@ func main() {
 
 	// ----------------------------------------------
 
-	store, err := postgresql.NewConnectPostgresql(&cfg)
+	// go run cmd/main.go migrate up
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(&cfg, os.Args[2:])
+		if err != nil {
+			log.Panic("Error migrate: ", logger.Error(err))
+		}
+		return
+	}
+
+	if cfg.MigrateOnStart {
+		err := runMigrate(&cfg, []string{"up"})
+		if err != nil {
+			log.Panic("Error migrate: ", logger.Error(err))
+			return
+		}
+	}
+
+	pool, err := postgresql.NewPool(&cfg)
 	if err != nil {
 		log.Panic("Error connect to postgresql: ", logger.Error(err))
 		return
 	}
+
+	store := postgresql.NewStore(pool)
 	defer store.CloseDB()
 
+	// go run cmd/main.go purge -days 30
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		err = purge(store, os.Args[2:])
+		if err != nil {
+			log.Panic("Error purge: ", logger.Error(err))
+		}
+		return
+	}
+
+	err = seedAdmin(&cfg, store)
+	if err != nil {
+		log.Panic("Error seed admin: ", logger.Error(err))
+		return
+	}
+
 	r := gin.New()
 
 	// call logger
 	r.Use(gin.Recovery(), gin.Logger())
 
-	api.NewApi(r, &cfg, store, log)
+	m := metrics.New(prometheus.NewRegistry())
 
-	fmt.Println("Server running on port", cfg.ServerHost+cfg.ServerPort)
-	err = r.Run(cfg.ServerHost + cfg.ServerPort)
+	err = m.RegisterPool(pool)
 	if err != nil {
+		log.Panic("Error register pool metrics: ", logger.Error(err))
+		return
+	}
+
+	handler := api.NewApi(r, &cfg, store, log, m)
+
+	server := &http.Server{
+		Addr:    cfg.ServerHost + cfg.ServerPort,
+		Handler: r,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	fmt.Println("Server running on port", cfg.ServerHost+cfg.ServerPort)
+
+	// SIGTERM is what orchestrators send before they kill the process
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err = <-serveErr:
 		log.Panic("Error listening server: ", logger.Error(err))
 		return
+	case sig := <-quit:
+		log.Info("Shutting down server: ", logger.Any("signal", sig.String()))
+	}
+
+	// fail readiness first and give load balancers time to notice, then
+	// stop accepting connections and let in-flight requests finish, the
+	// deferred CloseDB closes the pool once they have
+	handler.ShuttingDown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	if err != nil {
+		log.Error("Error shutting down server: ", logger.Error(err))
+		return
+	}
+}
+
+// seedAdmin makes sure the user configured by ADMIN_LOGIN exists and has
+// the admin role, so a fresh database can be managed through the api.
+func seedAdmin(cfg *config.Config, store storage.StorageI) error {
+	if len(cfg.AdminLogin) <= 0 {
+		return nil
+	}
+
+	var userId string
+
+	user, err := store.User().GetCredentials(context.Background(), &models.UserPrimaryKey{Login: cfg.AdminLogin})
+	switch {
+	case err == nil:
+		userId = user.Id
+	case errors.Is(err, storage.ErrNotFound):
+		if len(cfg.AdminPassword) <= 0 {
+			return errors.New("ADMIN_PASSWORD is required to create the admin user")
+		}
+
+		password, err := helper.HashPassword(cfg.AdminPassword)
+		if err != nil {
+			return err
+		}
+
+		userId, err = store.User().Create(context.Background(), &models.CreateUser{
+			FirstName: "Admin",
+			LastName:  "Admin",
+			Login:     cfg.AdminLogin,
+			Password:  password,
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	_, err = store.Role().AssignRole(context.Background(), &models.UserRole{
+		UserId: userId,
+		Role:   models.RoleAdmin,
+	})
+
+	return err
+}
+
+// purge removes the rows soft deleted more than -days ago. Orders go first
+// so that the clients and products they referred to can follow in the same
+// run.
+func purge(store storage.StorageI, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	days := flags.Int("days", 30, "remove rows deleted more than this many days ago")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *days < 0 {
+		return errors.New("days can not be negative")
+	}
+
+	purgers := []struct {
+		name  string
+		purge func(context.Context, *models.PurgeRequest) (int64, error)
+	}{
+		{"orders", store.Order().Purge},
+		{"products", store.Product().Purge},
+		{"clients", store.Client().Purge},
+		{"categories", store.Category().Purge},
+		{"users", store.User().Purge},
+	}
+
+	for _, p := range purgers {
+		rows, err := p.purge(context.Background(), &models.PurgeRequest{Days: *days})
+		if err != nil {
+			return fmt.Errorf("purge %s: %w", p.name, err)
+		}
+
+		fmt.Printf("purged %d %s\n", rows, p.name)
+	}
+
+	return nil
+}
+
+// runMigrate moves the schema with the migrations embedded in the binary:
+//
+//	migrate up
+//	migrate down [N]
+//	migrate status
+//	migrate goto N
+//	migrate force N
+//	migrate create [-dir migrations/postgres] name
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [N]|status|goto N|force N|create name")
+	}
+
+	command, args := args[0], args[1:]
+
+	if command == "create" {
+		flags := flag.NewFlagSet("create", flag.ContinueOnError)
+		dir := flags.String("dir", migrations.Dir, "directory of the migrations")
+
+		err := flags.Parse(args)
+		if err != nil {
+			return err
+		}
+
+		if flags.NArg() != 1 {
+			return errors.New("usage: migrate create [-dir dir] name")
+		}
+
+		up, down, err := migrate.Create(*dir, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+	}
+
+	// every command but down takes exactly one or no number
+	var number int
+	if len(args) > 1 {
+		return fmt.Errorf("unexpected arguments %v", args[1:])
+	}
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number %q", args[0])
+		}
+		number = n
+	}
+
+	dir, err := fs.Sub(migrations.Postgres, "postgres")
+	if err != nil {
+		return err
+	}
+
+	list, err := migrate.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	pool, err := postgresql.NewPool(cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	migrator := migrate.New(pool, list, os.Stdout)
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		if len(args) == 0 {
+			number = 1
+		}
+		return migrator.Down(ctx, number)
+	case "goto", "force":
+		if len(args) == 0 {
+			return fmt.Errorf("usage: migrate %s N", command)
+		}
+		if command == "goto" {
+			return migrator.Goto(ctx, number)
+		}
+		return migrator.Force(ctx, number)
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range status.Migrations {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%02d_%s\t%s\n", m.Version, m.Name, state)
+		}
+
+		fmt.Printf("version %d", status.Version)
+		if status.Dirty {
+			fmt.Print(" (dirty)")
+		}
+		fmt.Println()
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q", command)
 	}
 }
@@ -17,7 +17,8 @@ const (
 	// ReleaseMode indicates service mode is release.
 	ReleaseMode = "release"
 
-	TimeExpiredAt = time.Hour * 24
+	AccessTokenExpiredAt  = time.Minute * 30
+	RefreshTokenExpiredAt = time.Hour * 24 * 30
 )
 
 type Config struct {
@@ -25,6 +26,11 @@ type Config struct {
 
 	ServerHost string
 	ServerPort string
+	// how long readiness fails before the server stops listening, so load
+	// balancers stop sending requests first
+	ShutdownDelay time.Duration
+	// how long in-flight requests may take to finish on shutdown
+	ShutdownTimeout time.Duration
 
 	PostgresHost           string
 	PostgresUser           string
@@ -33,8 +39,15 @@ type Config struct {
 	PostgresPort           string
 	PostgresMaxConnections int32
 
+	// apply the embedded migrations before serving
+	MigrateOnStart bool
+
 	AuthSecretKey string
 
+	// first admin, created on startup when the login is set
+	AdminLogin    string
+	AdminPassword string
+
 	DefaultOffset int
 	DefaultLimit  int
 }
@@ -49,6 +62,8 @@ func Load() Config {
 
 	cfg.ServerHost = cast.ToString(getOrReturnDefaultValue("SERVICE_HOST", "localhost"))
 	cfg.ServerPort = cast.ToString(getOrReturnDefaultValue("HTTP_PORT", ":4001"))
+	cfg.ShutdownDelay = cast.ToDuration(getOrReturnDefaultValue("SHUTDOWN_DELAY", "5s"))
+	cfg.ShutdownTimeout = cast.ToDuration(getOrReturnDefaultValue("SHUTDOWN_TIMEOUT", "15s"))
 
 	cfg.PostgresHost = cast.ToString(getOrReturnDefaultValue("POSTGRES_HOST", "localhost"))
 	cfg.PostgresPort = cast.ToString(getOrReturnDefaultValue("POSTGRES_PORT", 5432))
@@ -56,12 +71,16 @@ func Load() Config {
 	cfg.PostgresPassword = cast.ToString(getOrReturnDefaultValue("POSTGRES_PASSWORD", "admin@111"))
 	cfg.PostgresDatabase = cast.ToString(getOrReturnDefaultValue("POSTGRES_DATABASE", "test_crud"))
 	cfg.PostgresMaxConnections = cast.ToInt32(getOrReturnDefaultValue("POSTGRES_MAXCONS", 20))
+	cfg.MigrateOnStart = cast.ToBool(getOrReturnDefaultValue("MIGRATE_ON_START", false))
 
 	cfg.DefaultOffset = cast.ToInt(getOrReturnDefaultValue("OFFSET", 0))
 	cfg.DefaultLimit = cast.ToInt(getOrReturnDefaultValue("LIMIT", 10))
 
 	cfg.AuthSecretKey = cast.ToString(getOrReturnDefaultValue("AUTH_SECRET_KEY", "secret"))
 
+	cfg.AdminLogin = cast.ToString(getOrReturnDefaultValue("ADMIN_LOGIN", ""))
+	cfg.AdminPassword = cast.ToString(getOrReturnDefaultValue("ADMIN_PASSWORD", ""))
+
 	return cfg
 }
 
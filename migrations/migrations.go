@@ -0,0 +1,15 @@
+// Package migrations embeds the schema migrations into the binary.
+package migrations
+
+import "embed"
+
+// Dir is where new Postgres migrations are created, relative to the root
+// of the repository.
+const Dir = "migrations/postgres"
+
+// Postgres holds the migrations of the Postgres schema in postgres/, named
+// {version}_{title}.up.sql and {version}_{title}.down.sql like
+// golang-migrate expects them.
+//
+//go:embed postgres/*.sql
+var Postgres embed.FS
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"app/pkg/migrate"
+	"io/fs"
+	"testing"
+)
+
+func TestPostgres(t *testing.T) {
+	dir, err := fs.Sub(Postgres, "postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := migrate.Load(dir)
+	if err != nil {
+		t.Fatalf("got: %v, expected: nil", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("got: no migrations, expected: embedded migrations")
+	}
+
+	for _, migration := range migrations {
+		if !migration.HasDown {
+			t.Errorf("migration %02d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
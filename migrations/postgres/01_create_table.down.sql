@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "order_products";
+DROP TABLE IF EXISTS "orders";
+DROP TABLE IF EXISTS "product";
+DROP TABLE IF EXISTS "category";
+DROP TABLE IF EXISTS "client";
+DROP TABLE IF EXISTS users;
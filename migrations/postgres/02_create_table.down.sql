@@ -1,9 +0,0 @@
-
-DROP TABLE "client";
-DROP TABLE "product";
-DROP TABLE "order";
-DROP TABLE "order_products";
-DROP TABLE "category";
-
-
-
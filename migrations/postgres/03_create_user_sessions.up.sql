@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_sessions (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  family_id UUID NOT NULL,
+  refresh_token_hash VARCHAR NOT NULL UNIQUE,
+  user_agent VARCHAR,
+  ip_address VARCHAR,
+  expires_at TIMESTAMP NOT NULL,
+  revoked_at TIMESTAMP,
+  replaced_by UUID,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
+CREATE INDEX IF NOT EXISTS user_sessions_family_id_idx ON user_sessions (family_id);
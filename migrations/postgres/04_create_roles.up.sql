@@ -0,0 +1,64 @@
+CREATE TABLE IF NOT EXISTS roles (
+  id UUID PRIMARY KEY NOT NULL,
+  name VARCHAR NOT NULL UNIQUE,
+  description VARCHAR,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+  id UUID PRIMARY KEY NOT NULL,
+  name VARCHAR NOT NULL UNIQUE,
+  description VARCHAR,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+  role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  permission_id UUID NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+  PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+  user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+  PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (id, name, description) VALUES
+  ('8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a01', 'admin', 'Full access'),
+  ('8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a02', 'manager', 'Manages catalog, clients and orders'),
+  ('8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a03', 'cashier', 'Serves clients and creates orders')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (id, name, description) VALUES
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b01', 'user:read', 'Read users'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b02', 'user:write', 'Create and update users'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b03', 'user:delete', 'Delete users'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b04', 'role:read', 'Read roles'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b05', 'role:assign', 'Assign and revoke user roles'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b06', 'category:write', 'Create, update and delete categories'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b07', 'product:write', 'Create and update products'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b08', 'product:price', 'Change product prices'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b09', 'product:delete', 'Delete products'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b10', 'client:write', 'Create and update clients'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b11', 'client:delete', 'Delete clients'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b12', 'order:create', 'Create orders and add order items'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b13', 'order:write', 'Update orders'),
+  ('4c6f0a7e-1d2b-4f5a-8e3c-6a9d2b1c0b14', 'order:delete', 'Delete orders')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles AS r
+JOIN permissions AS p ON
+  r.name = 'admin'
+  OR (r.name = 'manager' AND p.name IN (
+    'user:read', 'role:read', 'category:write', 'product:write', 'product:delete',
+    'client:write', 'client:delete', 'order:create', 'order:write', 'order:delete'
+  ))
+  OR (r.name = 'cashier' AND p.name IN (
+    'client:write', 'order:create', 'order:write'
+  ))
+ON CONFLICT DO NOTHING;
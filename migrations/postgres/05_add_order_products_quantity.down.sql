@@ -0,0 +1,5 @@
+ALTER TABLE "order_products"
+  DROP CONSTRAINT IF EXISTS "order_products_quantity_check",
+  DROP COLUMN IF EXISTS "quantity",
+  DROP COLUMN IF EXISTS "unit_price",
+  DROP COLUMN IF EXISTS "created_at";
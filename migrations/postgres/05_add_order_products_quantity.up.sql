@@ -0,0 +1,23 @@
+ALTER TABLE "order_products"
+  ADD COLUMN IF NOT EXISTS "quantity" integer NOT NULL DEFAULT 1,
+  ADD COLUMN IF NOT EXISTS "unit_price" float NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS "created_at" timestamp DEFAULT current_timestamp NOT NULL;
+
+ALTER TABLE "order_products"
+  ADD CONSTRAINT "order_products_quantity_check" CHECK ("quantity" > 0);
+
+-- snapshot the current product price for existing lines
+UPDATE "order_products" AS op
+SET "unit_price" = p."price"
+FROM "product" AS p
+WHERE p."id" = op."product_id";
+
+-- orders that have lines get their total from them
+UPDATE "orders" AS o
+SET "price" = t."total"
+FROM (
+  SELECT "order_id", SUM("quantity" * "unit_price") AS "total"
+  FROM "order_products"
+  GROUP BY "order_id"
+) AS t
+WHERE t."order_id" = o."id";
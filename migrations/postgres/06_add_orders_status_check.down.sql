@@ -0,0 +1,3 @@
+ALTER TABLE "orders"
+  DROP CONSTRAINT IF EXISTS "orders_status_check",
+  ALTER COLUMN "status" DROP NOT NULL;
@@ -0,0 +1,9 @@
+UPDATE "orders"
+SET "status" = 'new'
+WHERE "status" IS NULL
+  OR "status" NOT IN ('new', 'confirmed', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded');
+
+ALTER TABLE "orders"
+  ALTER COLUMN "status" SET NOT NULL,
+  ADD CONSTRAINT "orders_status_check"
+    CHECK ("status" IN ('new', 'confirmed', 'paid', 'shipped', 'delivered', 'cancelled', 'refunded'));
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "order_status_history" (
+  "id" uuid PRIMARY KEY,
+  "order_id" uuid NOT NULL REFERENCES "orders" ("id") ON DELETE CASCADE,
+  "from_status" varchar,
+  "to_status" varchar NOT NULL,
+  "from_price" float,
+  "to_price" float,
+  "changed_by" uuid REFERENCES "users" ("id") ON DELETE SET NULL,
+  "reason" varchar,
+  "created_at" timestamp default current_timestamp not null
+);
+
+CREATE INDEX IF NOT EXISTS "order_status_history_order_id_idx" ON "order_status_history" ("order_id", "created_at");
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS client_created_at_id_idx;
+DROP INDEX IF EXISTS product_created_at_id_idx;
+DROP INDEX IF EXISTS orders_created_at_id_idx;
+
+ALTER TABLE "orders" ALTER COLUMN "created_at" DROP NOT NULL;
+ALTER TABLE "product" ALTER COLUMN "created_at" DROP NOT NULL;
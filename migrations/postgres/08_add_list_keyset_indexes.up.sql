@@ -0,0 +1,11 @@
+-- a row tuple compared with NULL is NULL, rows without created_at would
+-- never show up after the first page, so the pages of product and orders
+-- need it set
+UPDATE "product" SET "created_at" = COALESCE("updated_at", now()) WHERE "created_at" IS NULL;
+UPDATE "orders" SET "created_at" = COALESCE("updated_at", now()) WHERE "created_at" IS NULL;
+ALTER TABLE "product" ALTER COLUMN "created_at" SET NOT NULL;
+ALTER TABLE "orders" ALTER COLUMN "created_at" SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS orders_created_at_id_idx ON "orders" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS product_created_at_id_idx ON "product" ("created_at" DESC, "id" DESC);
+CREATE INDEX IF NOT EXISTS client_created_at_id_idx ON "client" ("created_at" DESC, "id" DESC);
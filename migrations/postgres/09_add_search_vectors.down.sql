@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS client_search_vector_idx;
+DROP TRIGGER IF EXISTS client_search_vector_trigger ON "client";
+DROP FUNCTION IF EXISTS client_search_vector_update();
+ALTER TABLE "client" DROP COLUMN IF EXISTS "search_vector";
+
+DROP INDEX IF EXISTS product_search_vector_idx;
+DROP TRIGGER IF EXISTS product_search_vector_trigger ON "product";
+DROP FUNCTION IF EXISTS product_search_vector_update();
+ALTER TABLE "product" DROP COLUMN IF EXISTS "search_vector";
@@ -0,0 +1,46 @@
+-- product: name ranks above description
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "search_vector" tsvector;
+
+CREATE OR REPLACE FUNCTION product_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', COALESCE(NEW.name, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(NEW.description, '')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_search_vector_trigger ON "product";
+CREATE TRIGGER product_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF "name", "description" ON "product"
+  FOR EACH ROW EXECUTE FUNCTION product_search_vector_update();
+
+UPDATE "product" SET "search_vector" =
+  setweight(to_tsvector('simple', COALESCE("name", '')), 'A') ||
+  setweight(to_tsvector('simple', COALESCE("description", '')), 'B');
+
+CREATE INDEX IF NOT EXISTS product_search_vector_idx ON "product" USING GIN ("search_vector");
+
+-- client: names rank above the phone number, which is indexed both as
+-- written and as bare digits
+ALTER TABLE "client" ADD COLUMN IF NOT EXISTS "search_vector" tsvector;
+
+CREATE OR REPLACE FUNCTION client_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', COALESCE(NEW.first_name, '') || ' ' || COALESCE(NEW.last_name, '')), 'A') ||
+    setweight(to_tsvector('simple', COALESCE(NEW.phone_number, '') || ' ' || regexp_replace(COALESCE(NEW.phone_number, ''), '\D', '', 'g')), 'B');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS client_search_vector_trigger ON "client";
+CREATE TRIGGER client_search_vector_trigger
+  BEFORE INSERT OR UPDATE OF "first_name", "last_name", "phone_number" ON "client"
+  FOR EACH ROW EXECUTE FUNCTION client_search_vector_update();
+
+UPDATE "client" SET "search_vector" =
+  setweight(to_tsvector('simple', COALESCE("first_name", '') || ' ' || COALESCE("last_name", '')), 'A') ||
+  setweight(to_tsvector('simple', COALESCE("phone_number", '') || ' ' || regexp_replace(COALESCE("phone_number", ''), '\D', '', 'g')), 'B');
+
+CREATE INDEX IF NOT EXISTS client_search_vector_idx ON "client" USING GIN ("search_vector");
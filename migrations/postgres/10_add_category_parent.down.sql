@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS category_parent_id_idx;
+
+ALTER TABLE "category"
+  DROP CONSTRAINT IF EXISTS "category_parent_check",
+  DROP COLUMN IF EXISTS "parent_id";
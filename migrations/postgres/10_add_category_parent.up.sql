@@ -0,0 +1,5 @@
+ALTER TABLE "category"
+  ADD COLUMN IF NOT EXISTS "parent_id" uuid REFERENCES "category" ("id") ON DELETE RESTRICT,
+  ADD CONSTRAINT "category_parent_check" CHECK ("parent_id" <> "id");
+
+CREATE INDEX IF NOT EXISTS category_parent_id_idx ON "category" ("parent_id");
@@ -0,0 +1,16 @@
+-- fails while a deleted and a live user share a login, purge the deleted
+-- users first
+DROP INDEX IF EXISTS users_login_live_key;
+ALTER TABLE "users" ADD CONSTRAINT users_login_key UNIQUE ("login");
+
+DROP INDEX IF EXISTS orders_deleted_at_idx;
+DROP INDEX IF EXISTS client_deleted_at_idx;
+DROP INDEX IF EXISTS product_deleted_at_idx;
+DROP INDEX IF EXISTS category_deleted_at_idx;
+DROP INDEX IF EXISTS users_deleted_at_idx;
+
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "client" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "product" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "category" DROP COLUMN IF EXISTS "deleted_at";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "deleted_at";
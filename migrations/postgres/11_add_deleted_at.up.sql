@@ -0,0 +1,16 @@
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+ALTER TABLE "client" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "deleted_at" timestamp;
+
+-- the purge job looks rows up by deletion time
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON "users" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS category_deleted_at_idx ON "category" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS product_deleted_at_idx ON "product" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS client_deleted_at_idx ON "client" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS orders_deleted_at_idx ON "orders" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
+
+-- deleted users give their login up, only live users have to keep it unique
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS users_login_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_login_live_key ON "users" ("login") WHERE "deleted_at" IS NULL;
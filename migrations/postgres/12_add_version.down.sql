@@ -0,0 +1,4 @@
+ALTER TABLE "orders" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "client" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "product" DROP COLUMN IF EXISTS "version";
+ALTER TABLE "category" DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,7 @@
+-- version counts the client edits of a row, the repositories bump it on
+-- every write a client asks for, so writes made against an old read can be
+-- told apart and refused
+ALTER TABLE "category" ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
+ALTER TABLE "product" ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
+ALTER TABLE "client" ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
+ALTER TABLE "orders" ADD COLUMN IF NOT EXISTS "version" integer NOT NULL DEFAULT 1;
@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position of the last row of a keyset page. Clients only
+// ever see it base64 encoded and must treat it as opaque.
+type cursor struct {
+	CreatedAt string `json:"c"`
+	Id        string `json:"i"`
+}
+
+func EncodeCursor(createdAt, id string) string {
+	body, _ := json.Marshal(cursor{CreatedAt: createdAt, Id: id})
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// DecodeCursor returns the created_at and id EncodeCursor was given, or
+// ErrInvalidCursor for anything it could not have made.
+func DecodeCursor(value string) (createdAt, id string, err error) {
+	body, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+
+	var c cursor
+	err = json.Unmarshal(body, &c)
+	if err != nil || len(c.CreatedAt) == 0 || len(c.Id) == 0 {
+		return "", "", ErrInvalidCursor
+	}
+
+	// the storages cast both to timestamp and uuid, a tampered cursor must
+	// not get that far
+	_, err = time.Parse("2006-01-02 15:04:05.999999999", c.CreatedAt)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+
+	_, err = uuid.Parse(c.Id)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+
+	return c.CreatedAt, c.Id, nil
+}
@@ -0,0 +1,69 @@
+package helper
+
+import (
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Input     string
+		CreatedAt string
+		Id        string
+		WantErr   bool
+	}{
+		{
+			Name:      "round trip",
+			Input:     EncodeCursor("2023-05-01 12:00:00.123456", "8b5f3c1e-0c4e-4b8e-9d5a-1f0e6a7b2c3d"),
+			CreatedAt: "2023-05-01 12:00:00.123456",
+			Id:        "8b5f3c1e-0c4e-4b8e-9d5a-1f0e6a7b2c3d",
+		},
+		{
+			Name:    "not base64",
+			Input:   "%%%",
+			WantErr: true,
+		},
+		{
+			Name:    "not json",
+			Input:   "bm90IGpzb24",
+			WantErr: true,
+		},
+		{
+			Name:    "missing id",
+			Input:   EncodeCursor("2023-05-01 12:00:00", ""),
+			WantErr: true,
+		},
+		{
+			Name:    "invalid timestamp",
+			Input:   EncodeCursor("yesterday", "8b5f3c1e-0c4e-4b8e-9d5a-1f0e6a7b2c3d"),
+			WantErr: true,
+		},
+		{
+			Name:    "invalid id",
+			Input:   EncodeCursor("2023-05-01 12:00:00", "1; DROP TABLE product"),
+			WantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			createdAt, id, err := DecodeCursor(test.Input)
+
+			if test.WantErr {
+				if err == nil {
+					t.Errorf("%s: expected error", test.Name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.Name, err)
+				return
+			}
+
+			if createdAt != test.CreatedAt || id != test.Id {
+				t.Errorf("%s: got (%s, %s), want (%s, %s)", test.Name, createdAt, id, test.CreatedAt, test.Id)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package helper
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashPassword returns the bcrypt hash of the given password.
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+// IsHashedPassword reports whether the stored value is a bcrypt hash
+// rather than a legacy plain text password.
+func IsHashedPassword(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+// ComparePassword checks password against the stored value. Legacy plain
+// text values are compared in constant time as well.
+func ComparePassword(stored, password string) bool {
+	if IsHashedPassword(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
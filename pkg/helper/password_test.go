@@ -0,0 +1,61 @@
+package helper
+
+import (
+	"testing"
+)
+
+func TestComparePassword(t *testing.T) {
+	hashed, err := HashPassword("secret123")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+
+	tests := []struct {
+		Name     string
+		Stored   string
+		Password string
+		Output   bool
+	}{
+		{
+			Name:     "Hashed match",
+			Stored:   hashed,
+			Password: "secret123",
+			Output:   true,
+		},
+		{
+			Name:     "Hashed mismatch",
+			Stored:   hashed,
+			Password: "secret124",
+			Output:   false,
+		},
+		{
+			Name:     "Legacy plain text match",
+			Stored:   "secret123",
+			Password: "secret123",
+			Output:   true,
+		},
+		{
+			Name:     "Legacy plain text mismatch",
+			Stored:   "secret123",
+			Password: "secret",
+			Output:   false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+
+			got := ComparePassword(test.Stored, test.Password)
+
+			if got != test.Output {
+				t.Errorf("%s: got: %v, expected: %v", test.Name, got, test.Output)
+				return
+			}
+
+		})
+	}
+
+	if IsHashedPassword("secret123") {
+		t.Errorf("plain text password reported as hashed")
+	}
+}
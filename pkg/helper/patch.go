@@ -0,0 +1,80 @@
+package helper
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+)
+
+var ErrInvalidPatch = errors.New("merge patch must be a json object")
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the document: members
+// of the patch replace the ones of the document, objects are merged
+// recursively and a null removes the member.
+func MergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+
+		patchObject, ok := value.(map[string]interface{})
+		if !ok {
+			doc[key] = value
+			continue
+		}
+
+		docObject, _ := doc[key].(map[string]interface{})
+		doc[key] = MergePatch(docObject, patchObject)
+	}
+
+	return doc
+}
+
+// ApplyMergePatch patches the json representation of target, a pointer to
+// a struct, in place and returns the top level members the patch touched.
+func ApplyMergePatch(target interface{}, patch []byte) ([]string, error) {
+	var patchDoc map[string]interface{}
+	err := json.Unmarshal(patch, &patchDoc)
+	if err != nil || patchDoc == nil {
+		return nil, ErrInvalidPatch
+	}
+
+	body, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc map[string]interface{}
+	err = json.Unmarshal(body, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err = json.Marshal(MergePatch(doc, patchDoc))
+	if err != nil {
+		return nil, err
+	}
+
+	// members removed by the patch fall back to their zero value
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+
+	err = json.Unmarshal(body, target)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(patchDoc))
+	for key := range patchDoc {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
@@ -0,0 +1,89 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+)
+
+type patchTarget struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Input   string
+		Output  patchTarget
+		Fields  []string
+		WantErr bool
+	}{
+		{
+			Name:   "only price",
+			Input:  `{"price": 12.5}`,
+			Output: patchTarget{Name: "pen", Description: "blue", Price: 12.5},
+			Fields: []string{"price"},
+		},
+		{
+			Name:   "null clears",
+			Input:  `{"description": null, "name": "pencil"}`,
+			Output: patchTarget{Name: "pencil", Price: 10},
+			Fields: []string{"description", "name"},
+		},
+		{
+			Name:   "empty patch",
+			Input:  `{}`,
+			Output: patchTarget{Name: "pen", Description: "blue", Price: 10},
+			Fields: []string{},
+		},
+		{
+			Name:    "not an object",
+			Input:   `[1, 2]`,
+			WantErr: true,
+		},
+		{
+			Name:    "wrong type",
+			Input:   `{"price": "free"}`,
+			WantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			target := patchTarget{Name: "pen", Description: "blue", Price: 10}
+
+			fields, err := ApplyMergePatch(&target, []byte(test.Input))
+			if test.WantErr {
+				if err == nil {
+					t.Errorf("%s: expected an error", test.Name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%s: %v", test.Name, err)
+			}
+
+			if target != test.Output {
+				t.Errorf("%s: got %+v, want %+v", test.Name, target, test.Output)
+			}
+
+			if !reflect.DeepEqual(fields, test.Fields) {
+				t.Errorf("%s: fields got %v, want %v", test.Name, fields, test.Fields)
+			}
+		})
+	}
+}
+
+func TestMergePatchNested(t *testing.T) {
+	doc := map[string]interface{}{"a": map[string]interface{}{"b": "c", "d": "e"}}
+	patch := map[string]interface{}{"a": map[string]interface{}{"d": nil, "f": "g"}}
+
+	got := MergePatch(doc, patch)
+	want := map[string]interface{}{"a": map[string]interface{}{"b": "c", "f": "g"}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+)
+
+// QueryBuilder composes a WHERE clause out of conditions with positional
+// ($1, $2, ...) arguments, so that request values never end up inside the
+// SQL text.
+type QueryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{}
+}
+
+// Where adds a condition joined with AND. Every "?" in the condition is
+// replaced with the placeholder of the next argument.
+func (q *QueryBuilder) Where(condition string, args ...interface{}) *QueryBuilder {
+	var (
+		sb    strings.Builder
+		index int
+	)
+
+	for _, ch := range condition {
+		if ch == '?' && index < len(args) {
+			sb.WriteString(q.Arg(args[index]))
+			index++
+			continue
+		}
+		sb.WriteRune(ch)
+	}
+
+	q.conditions = append(q.conditions, "("+sb.String()+")")
+
+	return q
+}
+
+// Search adds a case-insensitive substring match of the value against any
+// of the given columns. The value is escaped so that "%" and "_" match
+// literally.
+func (q *QueryBuilder) Search(value string, columns ...string) *QueryBuilder {
+	if len(value) == 0 || len(columns) == 0 {
+		return q
+	}
+
+	placeholder := q.Arg("%" + EscapeLike(value) + "%")
+
+	matches := make([]string, 0, len(columns))
+	for _, column := range columns {
+		matches = append(matches, column+" ILIKE "+placeholder)
+	}
+
+	q.conditions = append(q.conditions, "("+strings.Join(matches, " OR ")+")")
+
+	return q
+}
+
+// Arg binds a value and returns its placeholder, for the parts of a query
+// that are not conditions (ORDER BY expressions, LIMIT, ...).
+func (q *QueryBuilder) Arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// WhereClause returns " WHERE ..." or an empty string when there are no
+// conditions.
+func (q *QueryBuilder) WhereClause() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ") + " "
+}
+
+func (q *QueryBuilder) Args() []interface{} {
+	return q.args
+}
+
+// EscapeLike escapes the LIKE wildcards of s using the default "\" escape.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
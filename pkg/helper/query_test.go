@@ -0,0 +1,67 @@
+package helper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		Name  string
+		Build func(q *QueryBuilder)
+		Where string
+		Args  []interface{}
+	}{
+		{
+			Name:  "empty",
+			Build: func(q *QueryBuilder) {},
+			Where: "",
+		},
+		{
+			Name: "conditions",
+			Build: func(q *QueryBuilder) {
+				q.Where("category_id = ?", "c1").Where("price BETWEEN ? AND ?", 10, 20)
+			},
+			Where: " WHERE (category_id = $1) AND (price BETWEEN $2 AND $3) ",
+			Args:  []interface{}{"c1", 10, 20},
+		},
+		{
+			Name: "search injection",
+			Build: func(q *QueryBuilder) {
+				q.Search("x' OR 1=1 --", "first_name", "last_name")
+			},
+			Where: " WHERE (first_name ILIKE $1 OR last_name ILIKE $1) ",
+			Args:  []interface{}{"%x' OR 1=1 --%"},
+		},
+		{
+			Name: "search wildcards",
+			Build: func(q *QueryBuilder) {
+				q.Where("status = ?", "new").Search(`50%_\`, "name")
+			},
+			Where: " WHERE (status = $1) AND (name ILIKE $2) ",
+			Args:  []interface{}{"new", `%50\%\_\\%`},
+		},
+		{
+			Name: "empty search",
+			Build: func(q *QueryBuilder) {
+				q.Search("", "name")
+			},
+			Where: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			q := NewQueryBuilder()
+			test.Build(q)
+
+			if got := q.WhereClause(); got != test.Where {
+				t.Errorf("%s: where got %q, want %q", test.Name, got, test.Where)
+			}
+
+			if !reflect.DeepEqual(q.Args(), test.Args) {
+				t.Errorf("%s: args got %v, want %v", test.Name, q.Args(), test.Args)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package helper
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"strings"
 	"time"
@@ -10,8 +14,11 @@ import (
 )
 
 type TokenInfo struct {
-	UserID     string `json:"user_id"`
-	ClientType string `json:"client_type"`
+	UserID      string   `json:"user_id"`
+	SessionID   string   `json:"session_id"`
+	ClientType  string   `json:"client_type"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
 }
 
 // GenerateJWT ...
@@ -51,7 +58,10 @@ func ParseClaims(token string, secretKey string) (result TokenInfo, err error) {
 		return result, err
 	}
 
+	result.SessionID = cast.ToString(claims["session_id"])
 	result.ClientType = cast.ToString(claims["client_type"])
+	result.Roles = cast.ToStringSlice(claims["roles"])
+	result.Permissions = cast.ToStringSlice(claims["permissions"])
 
 	return
 }
@@ -64,7 +74,9 @@ func ExtractClaims(tokenString string, tokenSecretKey string) (jwt.MapClaims, er
 	)
 
 	token, err = jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// check token signing method etc
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
 		return []byte(tokenSecretKey), nil
 	})
 
@@ -80,10 +92,28 @@ func ExtractClaims(tokenString string, tokenSecretKey string) (jwt.MapClaims, er
 	return claims, nil
 }
 
+// GenerateRefreshToken returns a random opaque refresh token
+func GenerateRefreshToken() (string, error) {
+	buffer := make([]byte, 32)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// HashToken returns the hex encoded sha256 of token, only hashes of
+// refresh tokens are stored in the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // ExtractToken checks and returns token part of input string
 func ExtractToken(bearer string) (token string, err error) {
 	strArr := strings.Split(bearer, " ")
-	if len(strArr) == 2 {
+	if len(strArr) == 2 && strings.EqualFold(strArr[0], "Bearer") && len(strArr[1]) > 0 {
 		return strArr[1], nil
 	}
 	return token, errors.New("wrong token format")
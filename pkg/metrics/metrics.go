@@ -0,0 +1,105 @@
+// Package metrics exposes the Prometheus metrics of the service: requests,
+// repository queries, the connection pool and business events.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	queryDuration   *prometheus.HistogramVec
+
+	ordersCreated prometheus.Counter
+	orderValue    prometheus.Histogram
+	stockOuts     prometheus.Counter
+}
+
+// New registers the metrics on registry. Tests pass a registry of their
+// own, so their counts do not mix.
+func New(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by route template and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests by route template.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of repository methods.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repo", "method"}),
+		ordersCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "orders_created_total",
+			Help: "Number of orders created.",
+		}),
+		orderValue: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "order_value",
+			Help:    "Price of the orders when they are created.",
+			Buckets: prometheus.ExponentialBuckets(1000, 4, 10),
+		}),
+		stockOuts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "stock_outs_total",
+			Help: "Number of order lines rejected for insufficient stock.",
+		}),
+	}
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.ordersCreated,
+		m.orderValue,
+		m.stockOuts,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterPool reports the stats of pool on every scrape.
+func (m *Metrics) RegisterPool(pool *pgxpool.Pool) error {
+	return m.registry.Register(newPoolCollector(pool))
+}
+
+// ObserveRequest records a request to route, the template of the path so
+// that ids do not make a series each.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records a call of a repository method.
+func (m *Metrics) ObserveQuery(repo, method string, duration time.Duration) {
+	m.queryDuration.WithLabelValues(repo, method).Observe(duration.Seconds())
+}
+
+func (m *Metrics) OrderCreated(price float64) {
+	m.ordersCreated.Inc()
+	m.orderValue.Observe(price)
+}
+
+func (m *Metrics) StockOut() {
+	m.stockOuts.Inc()
+}
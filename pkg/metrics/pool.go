@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads the stats of a pgx pool when it is scraped.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	maxConns          *prometheus.Desc
+	totalConns        *prometheus.Desc
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	acquires          *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+}
+
+func newPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	return &poolCollector{
+		pool:              pool,
+		maxConns:          prometheus.NewDesc("db_pool_max_conns", "Maximum size of the pool.", nil, nil),
+		totalConns:        prometheus.NewDesc("db_pool_total_conns", "Connections in the pool.", nil, nil),
+		acquiredConns:     prometheus.NewDesc("db_pool_acquired_conns", "Connections in use.", nil, nil),
+		idleConns:         prometheus.NewDesc("db_pool_idle_conns", "Idle connections.", nil, nil),
+		constructingConns: prometheus.NewDesc("db_pool_constructing_conns", "Connections being opened.", nil, nil),
+		acquires:          prometheus.NewDesc("db_pool_acquires_total", "Connections acquired from the pool.", nil, nil),
+		emptyAcquires:     prometheus.NewDesc("db_pool_empty_acquires_total", "Acquires that waited because the pool was empty.", nil, nil),
+		canceledAcquires:  prometheus.NewDesc("db_pool_canceled_acquires_total", "Acquires canceled while waiting.", nil, nil),
+		acquireDuration:   prometheus.NewDesc("db_pool_acquire_duration_seconds_total", "Time spent acquiring connections.", nil, nil),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxConns
+	ch <- c.totalConns
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.constructingConns
+	ch <- c.acquires
+	ch <- c.emptyAcquires
+	ch <- c.canceledAcquires
+	ch <- c.acquireDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
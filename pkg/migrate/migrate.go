@@ -0,0 +1,405 @@
+// Package migrate applies sql migrations to Postgres. The applied version
+// is kept in the schema_migrations table the way golang-migrate keeps it,
+// so a database migrated by either tool can be taken over by the other.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Table records the version of the schema.
+const Table = "schema_migrations"
+
+var (
+	// ErrDirty is returned when a previous migration failed half way, the
+	// schema has to be fixed by hand and the version set with Force.
+	ErrDirty = errors.New("database is dirty, fix it and force the version")
+	// ErrUnknownVersion is returned for versions there is no migration for.
+	ErrUnknownVersion = errors.New("unknown migration version")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration is a pair of up and down migration files.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// HasDown tells whether there is a down file, an empty one is valid.
+	HasDown bool
+}
+
+// Load reads the migrations of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is already used by %s", entry.Name(), version, m.Name)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+			m.HasDown = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if len(strings.TrimSpace(m.Up)) == 0 {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Create adds the empty up and down files of a new migration to dir,
+// numbered after the last one, and returns their paths.
+func Create(dir, name string) (string, string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if len(name) == 0 {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+
+	version := 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%02d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+
+	for _, path := range []string{up, down} {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", err
+		}
+
+		err = f.Close()
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return up, down, nil
+}
+
+// Migrator moves the schema of a database between the versions of its
+// migrations. Only one migrator works on a database at a time, the others
+// wait for its advisory lock.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	out        io.Writer
+}
+
+// New returns a migrator applying migrations to the database of pool and
+// reporting the applied files to out, which may be nil.
+func New(pool *pgxpool.Pool, migrations []Migration, out io.Writer) *Migrator {
+	if out == nil {
+		out = io.Discard
+	}
+
+	return &Migrator{
+		pool:       pool,
+		migrations: migrations,
+		out:        out,
+	}
+}
+
+// Status is the version of the schema and the migrations that are applied.
+type Status struct {
+	Version    int
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	status := &Status{}
+
+	err := m.locked(ctx, func(conn *pgxpool.Conn, version int, dirty bool) error {
+		status.Version, status.Dirty = version, dirty
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range m.migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Migration: migration,
+			Applied:   migration.Version <= status.Version,
+		})
+	}
+
+	return status, nil
+}
+
+// Up applies every migration that is not applied yet.
+func (m *Migrator) Up(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return nil
+	}
+
+	return m.Goto(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, version int, dirty bool) error {
+		if dirty {
+			return fmt.Errorf("%w: version %d", ErrDirty, version)
+		}
+
+		i, err := m.index(version)
+		if err != nil {
+			return err
+		}
+
+		target := 0
+		if i-steps >= 0 {
+			target = m.migrations[i-steps].Version
+		}
+
+		return m.migrate(ctx, conn, version, target)
+	})
+}
+
+// Goto applies or rolls back migrations until the schema is at version, 0
+// rolls back all of them.
+func (m *Migrator) Goto(ctx context.Context, version int) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, current int, dirty bool) error {
+		if dirty {
+			return fmt.Errorf("%w: version %d", ErrDirty, current)
+		}
+
+		_, err := m.index(version)
+		if err != nil {
+			return err
+		}
+
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Force records version as the clean version of the schema without
+// running any migration, after a failed migration was fixed by hand.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.locked(ctx, func(conn *pgxpool.Conn, current int, dirty bool) error {
+		_, err := m.index(version)
+		if err != nil {
+			return err
+		}
+
+		return setVersion(ctx, conn, version, false)
+	})
+}
+
+// index returns the position of the migration of version, -1 for version
+// 0 which stands for an empty schema.
+func (m *Migrator) index(version int) (int, error) {
+	if version == 0 {
+		return -1, nil
+	}
+
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+}
+
+// migrate runs the migrations between the current version and target one
+// by one. Each one is marked dirty until it has run, like golang-migrate
+// does.
+func (m *Migrator) migrate(ctx context.Context, conn *pgxpool.Conn, current, target int) error {
+	if target >= current {
+		for _, migration := range m.migrations {
+			if migration.Version <= current || migration.Version > target {
+				continue
+			}
+
+			err := m.run(ctx, conn, migration.Version, migration.Up, fmt.Sprintf("%d_%s.up.sql", migration.Version, migration.Name))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > current || migration.Version <= target {
+			continue
+		}
+
+		if !migration.HasDown {
+			return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+
+		previous := 0
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+
+		err := m.run(ctx, conn, previous, migration.Down, fmt.Sprintf("%d_%s.down.sql", migration.Version, migration.Name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run runs the statements of a migration file, which bring the schema to
+// version. The statements are sent together, so Postgres runs them in a
+// single transaction.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, version int, statements, name string) error {
+	err := setVersion(ctx, conn, version, true)
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(statements)) > 0 {
+		_, err = conn.Exec(ctx, statements)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	err = setVersion(ctx, conn, version, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(m.out, "applied", name)
+
+	return nil
+}
+
+// locked runs fn with the advisory lock of the migrations held on conn and
+// the current version of the schema.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn, version int, dirty bool) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock(hashtext($1))", Table)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", Table)
+
+	_, err = conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS "+Table+" (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	var (
+		version int
+		dirty   bool
+	)
+
+	err = conn.QueryRow(ctx, "SELECT version, dirty FROM "+Table+" LIMIT 1").Scan(&version, &dirty)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	// golang-migrate writes -1 for a dirty empty schema
+	if version < 0 {
+		version = 0
+	}
+
+	return fn(conn, version, dirty)
+}
+
+// setVersion records the version of the schema, an empty table stands for
+// the clean empty schema.
+func setVersion(ctx context.Context, conn *pgxpool.Conn, version int, dirty bool) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM "+Table)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 || dirty {
+		if version == 0 {
+			version = -1
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO "+Table+" (version, dirty) VALUES ($1, $2)", version, dirty)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Files    fstest.MapFS
+		Versions []int
+		WantErr  bool
+	}{
+		{
+			Name: "ordered by version",
+			Files: fstest.MapFS{
+				"10_add_index.up.sql":      {Data: []byte("CREATE INDEX ...")},
+				"10_add_index.down.sql":    {Data: []byte("DROP INDEX ...")},
+				"02_create_table.up.sql":   {Data: []byte("CREATE TABLE ...")},
+				"02_create_table.down.sql": {Data: []byte("DROP TABLE ...")},
+				"README.md":                {Data: []byte("not a migration")},
+			},
+			Versions: []int{2, 10},
+		},
+		{
+			Name: "missing up",
+			Files: fstest.MapFS{
+				"01_create_table.down.sql": {Data: []byte("DROP TABLE ...")},
+			},
+			WantErr: true,
+		},
+		{
+			Name: "version used twice",
+			Files: fstest.MapFS{
+				"01_create_table.up.sql": {Data: []byte("CREATE TABLE ...")},
+				"01_create_index.up.sql": {Data: []byte("CREATE INDEX ...")},
+			},
+			WantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			migrations, err := Load(test.Files)
+			if test.WantErr {
+				if err == nil {
+					t.Errorf("%s: got: nil, expected: error", test.Name)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%s: got: %v, expected: nil", test.Name, err)
+			}
+
+			if len(migrations) != len(test.Versions) {
+				t.Fatalf("%s: got: %d migrations, expected: %d", test.Name, len(migrations), len(test.Versions))
+			}
+
+			for i, migration := range migrations {
+				if migration.Version != test.Versions[i] {
+					t.Errorf("%s: got: version %d, expected: %d", test.Name, migration.Version, test.Versions[i])
+				}
+				if !migration.HasDown || len(migration.Down) == 0 {
+					t.Errorf("%s: version %d has no down", test.Name, migration.Version)
+				}
+			}
+		})
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "07_create_table.up.sql"), []byte("CREATE TABLE ..."), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, down, err := Create(dir, "Add Order Notes")
+	if err != nil {
+		t.Fatalf("got: %v, expected: nil", err)
+	}
+
+	if filepath.Base(up) != "08_add_order_notes.up.sql" || filepath.Base(down) != "08_add_order_notes.down.sql" {
+		t.Errorf("got: %s %s, expected: 08_add_order_notes", filepath.Base(up), filepath.Base(down))
+	}
+
+	_, _, err = Create(dir, "  ")
+	if err == nil {
+		t.Error("got: nil, expected: error for an empty name")
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write collides with an existing row,
+	// like a second user with the same login.
+	ErrConflict = errors.New("already exists")
+	// ErrForeignKey is returned when a write refers to a row that does not
+	// exist, or removes a row that is still referred to.
+	ErrForeignKey = errors.New("referenced row does not exist or is still in use")
+	// ErrCheckViolation is returned when a write breaks a check or not null
+	// constraint of the schema.
+	ErrCheckViolation = errors.New("value violates a constraint")
+	// ErrVersionMismatch is returned when a write expects a version of the
+	// row that is no longer the current one.
+	ErrVersionMismatch = errors.New("row has been changed since it was read")
+
+	// ErrInsufficientStock is returned when a product does not have enough
+	// quantity left to be added to an order.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrOrderFrozen is returned when lines of an order are changed after
+	// it has been confirmed.
+	ErrOrderFrozen = errors.New("order lines can only be changed while the order is new")
+	// ErrInvalidTransition is returned when an order can not move from its
+	// current status to the requested one.
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrCategoryCycle is returned when a category is moved under itself or
+	// one of its descendants.
+	ErrCategoryCycle = errors.New("category can not be moved under itself or its descendants")
+)
+
+// Error is a constraint error of the database translated to one of the
+// errors above, errors.Is matches it against Err. Field is the column the
+// constraint is about, when known.
+type Error struct {
+	Err        error
+	Field      string
+	Constraint string
+}
+
+func (e *Error) Error() string {
+	if len(e.Field) > 0 {
+		return e.Field + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
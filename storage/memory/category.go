@@ -0,0 +1,307 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+type category struct {
+	row
+	id       string
+	parentId string
+	name     string
+}
+
+func (c category) model() *models.Category {
+	return &models.Category{
+		Id:        c.id,
+		ParentId:  c.parentId,
+		Name:      c.name,
+		CreatedAt: formatTime(c.createdAt),
+		UpdatedAt: formatTime(c.updatedAt),
+		Version:   c.version,
+		DeletedAt: formatTime(c.deletedAt),
+	}
+}
+
+type categoryRepo struct {
+	s *Store
+}
+
+// checkParent fails when parentId is not a live category, or when it is
+// the category itself or one of its descendants.
+func checkParent(d *data, id, parentId string) error {
+	if len(parentId) <= 0 {
+		return nil
+	}
+
+	visited := map[string]bool{}
+	for ancestor := parentId; len(ancestor) > 0 && !visited[ancestor]; ancestor = d.categories[ancestor].parentId {
+		if ancestor == id {
+			return storage.ErrCategoryCycle
+		}
+		visited[ancestor] = true
+	}
+
+	if parent, ok := d.categories[parentId]; !ok || !parent.live() {
+		return foreignKey("parent_id", "category_parent_id_fkey")
+	}
+
+	return nil
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		err := checkParent(d, id, req.ParentId)
+		if err != nil {
+			return err
+		}
+
+		d.categories[id] = category{
+			row:      newRow(d, now()),
+			id:       id,
+			parentId: req.ParentId,
+			name:     req.Name,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
+	var resp *models.Category
+
+	err := r.s.read(func(d *data) error {
+		c, ok := d.categories[req.Id]
+		if !ok || !c.visible(req.IncludeDeleted) {
+			return storage.ErrNotFound
+		}
+
+		resp = c.model()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+	resp := &models.GetListCategoryResponse{}
+
+	err := r.s.read(func(d *data) error {
+		var categories []category
+		for _, c := range d.categories {
+			if c.visible(req.IncludeDeleted) && containsFold(req.Search, c.name) {
+				categories = append(categories, c)
+			}
+		}
+
+		sort.Slice(categories, func(i, j int) bool {
+			return categories[i].seq < categories[j].seq
+		})
+
+		start, end := pageBounds(len(categories), req.Offset, req.Limit)
+		for _, c := range categories[start:end] {
+			resp.Categories = append(resp.Categories, c.model())
+		}
+
+		if len(resp.Categories) > 0 {
+			resp.Count = len(categories)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	return r.patch(req.Id, req.Version, &req.ParentId, &req.Name)
+}
+
+func (r *categoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	return r.patch(req.Id, req.Version, req.ParentId, req.Name)
+}
+
+// patch changes the fields that are not nil of the live category id.
+func (r *categoryRepo) patch(id string, version int, parentId, name *string) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		if parentId != nil {
+			err := checkParent(d, id, *parentId)
+			if err != nil {
+				return err
+			}
+		}
+
+		c, ok := d.categories[id]
+		if !ok || !c.live() {
+			return nil
+		}
+
+		if !c.matchesVersion(version) {
+			return storage.ErrVersionMismatch
+		}
+
+		if parentId != nil {
+			c.parentId = *parentId
+		}
+		if name != nil {
+			c.name = *name
+		}
+		c.touch(now())
+
+		d.categories[id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		c, ok := d.categories[req.Id]
+		if !ok || !c.live() {
+			return nil
+		}
+
+		if !c.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		c.softDelete(now())
+
+		d.categories[c.id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// GetTree returns the live root categories with their live descendants
+// nested in Children, siblings ordered by name.
+func (r *categoryRepo) GetTree(ctx context.Context) (*models.GetCategoryTreeResponse, error) {
+	resp := &models.GetCategoryTreeResponse{Categories: []*models.Category{}}
+
+	err := r.s.read(func(d *data) error {
+		children := map[string][]category{}
+		for _, c := range d.categories {
+			if c.live() {
+				children[c.parentId] = append(children[c.parentId], c)
+			}
+		}
+
+		var build func(parentId string) []*models.Category
+		build = func(parentId string) []*models.Category {
+			level := children[parentId]
+			sort.Slice(level, func(i, j int) bool {
+				return level[i].name < level[j].name
+			})
+
+			var nodes []*models.Category
+			for _, c := range level {
+				node := c.model()
+				node.Version = 0
+				node.DeletedAt = ""
+				node.Children = build(c.id)
+				nodes = append(nodes, node)
+			}
+
+			return nodes
+		}
+
+		resp.Categories = append(resp.Categories, build("")...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		c, ok := d.categories[req.Id]
+		if !ok || c.live() {
+			return nil
+		}
+
+		c.restore(now())
+
+		d.categories[c.id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Purge removes the categories deleted more than req.Days days ago that no
+// product or subcategory refers to any more.
+func (r *categoryRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		referenced := map[string]bool{}
+		for _, p := range d.products {
+			referenced[p.categoryId] = true
+		}
+		for _, c := range d.categories {
+			referenced[c.parentId] = true
+		}
+
+		for id, c := range d.categories {
+			if c.purgeable(req.Days, at) && !referenced[id] {
+				delete(d.categories, id)
+				rows++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
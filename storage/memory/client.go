@@ -0,0 +1,309 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type client struct {
+	row
+	id          string
+	firstName   string
+	lastName    string
+	phoneNumber string
+}
+
+func (c client) model() *models.Client {
+	return &models.Client{
+		Id:          c.id,
+		FirstName:   c.firstName,
+		LastName:    c.lastName,
+		PhoneNumber: c.phoneNumber,
+		CreatedAt:   formatTime(c.createdAt),
+		UpdatedAt:   formatTime(c.updatedAt),
+		Version:     c.version,
+		DeletedAt:   formatTime(c.deletedAt),
+	}
+}
+
+// phoneDigits is the phone number without its punctuation, which the
+// search vector indexes as well.
+func (c client) phoneDigits() string {
+	return strings.Map(func(ch rune) rune {
+		if ch < '0' || ch > '9' {
+			return -1
+		}
+		return ch
+	}, c.phoneNumber)
+}
+
+type clientRepo struct {
+	s *Store
+}
+
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		d.clients[id] = client{
+			row:         newRow(d, now()),
+			id:          id,
+			firstName:   req.FirstName,
+			lastName:    req.LastName,
+			phoneNumber: req.PhoneNumber,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *clientRepo) GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error) {
+	var resp *models.Client
+
+	err := r.s.read(func(d *data) error {
+		c, ok := d.clients[req.Id]
+		if !ok || !c.visible(req.IncludeDeleted) {
+			return storage.ErrNotFound
+		}
+
+		resp = c.model()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// clientMatch is a client of a list with its search rank.
+type clientMatch struct {
+	client
+	rank float64
+}
+
+func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientRequest) (*models.GetListClientResponse, error) {
+	resp := &models.GetListClientResponse{}
+
+	err := r.s.read(func(d *data) error {
+		query := parseTextQuery(req.Search)
+
+		var matches []clientMatch
+		for _, c := range d.clients {
+			if !c.visible(req.IncludeDeleted) {
+				continue
+			}
+
+			var rank float64
+			if len(req.Search) > 0 {
+				// names weigh A and the phone number B, as in the search vector
+				rank = query.rank([]string{c.firstName + " " + c.lastName, c.phoneNumber + " " + c.phoneDigits()}, []float64{1, 0.4})
+				if rank <= 0 {
+					continue
+				}
+			}
+
+			matches = append(matches, clientMatch{client: c, rank: rank})
+		}
+
+		var page []clientMatch
+		if req.Keyset {
+			items := make([]keysetItem, 0, len(matches))
+			for i, m := range matches {
+				items = append(items, keysetItem{index: i, createdAt: m.createdAt, id: m.id})
+			}
+
+			indexes, err := keysetPage(items, req.Cursor, req.Limit)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range indexes {
+				page = append(page, matches[i])
+			}
+		} else {
+			// the best matches come first
+			sort.Slice(matches, func(i, j int) bool {
+				if matches[i].rank != matches[j].rank {
+					return matches[i].rank > matches[j].rank
+				}
+				if len(req.Search) > 0 {
+					return matches[i].id < matches[j].id
+				}
+				return matches[i].seq < matches[j].seq
+			})
+
+			start, end := pageBounds(len(matches), req.Offset, req.Limit)
+			page = matches[start:end]
+
+			if len(page) > 0 {
+				resp.Count = len(matches)
+			}
+		}
+
+		for _, m := range page {
+			item := m.model()
+			item.Rank = m.rank
+			if len(req.Search) > 0 {
+				item.Highlight = query.highlight(m.firstName + " " + m.lastName + " " + m.phoneNumber)
+			}
+
+			resp.Clients = append(resp.Clients, item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Keyset && len(resp.Clients) > keysetLimit(req.Limit) {
+		resp.Clients = resp.Clients[:keysetLimit(req.Limit)]
+
+		last := resp.Clients[len(resp.Clients)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return resp, nil
+}
+
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
+	return r.patch(&models.PatchClient{
+		Id:          req.Id,
+		FirstName:   &req.FirstName,
+		LastName:    &req.LastName,
+		PhoneNumber: &req.PhoneNumber,
+		Version:     req.Version,
+	})
+}
+
+func (r *clientRepo) Patch(ctx context.Context, req *models.PatchClient) (int64, error) {
+	return r.patch(req)
+}
+
+func (r *clientRepo) patch(req *models.PatchClient) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		c, ok := d.clients[req.Id]
+		if !ok || !c.live() {
+			return nil
+		}
+
+		if !c.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		if req.FirstName != nil {
+			c.firstName = *req.FirstName
+		}
+		if req.LastName != nil {
+			c.lastName = *req.LastName
+		}
+		if req.PhoneNumber != nil {
+			c.phoneNumber = *req.PhoneNumber
+		}
+		c.touch(now())
+
+		d.clients[c.id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		c, ok := d.clients[req.Id]
+		if !ok || !c.live() {
+			return nil
+		}
+
+		if !c.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		c.softDelete(now())
+
+		d.clients[c.id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		c, ok := d.clients[req.Id]
+		if !ok || c.live() {
+			return nil
+		}
+
+		c.restore(now())
+
+		d.clients[c.id] = c
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Purge removes the clients deleted more than req.Days days ago that no
+// order refers to any more.
+func (r *clientRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		referenced := map[string]bool{}
+		for _, o := range d.orders {
+			referenced[o.clientId] = true
+		}
+
+		for id, c := range d.clients {
+			if c.purgeable(req.Days, at) && !referenced[id] {
+				delete(d.clients, id)
+				rows++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
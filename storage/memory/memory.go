@@ -0,0 +1,385 @@
+// Package memory is a storage.StorageI kept in memory. It follows the
+// semantics of the postgresql storage, errors included, so handlers can be
+// tested without a database.
+package memory
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is safe for concurrent use. Every write works on a copy of the
+// data that replaces it only when the write succeeds, so a failed write
+// leaves nothing behind, the same as a failed statement.
+type Store struct {
+	mu   *sync.RWMutex
+	data *data
+	// tx is set on the store passed to WithTx, which runs with the lock of
+	// its root store already held
+	tx bool
+
+	product  *productRepo
+	category *categoryRepo
+	client   *clientRepo
+	order    *orderRepo
+	user     *userRepo
+	session  *sessionRepo
+	role     *roleRepo
+}
+
+// data holds the rows of every table. Rows are stored by value, so copying
+// the maps is enough to copy the data.
+type data struct {
+	seq        int64
+	users      map[string]user
+	sessions   map[string]session
+	roles      map[string]role
+	userRoles  map[userRoleKey]struct{}
+	categories map[string]category
+	products   map[string]product
+	clients    map[string]client
+	orders     map[string]order
+	lines      map[string]orderLine
+	history    []orderHistory
+}
+
+func NewStore() *Store {
+	return newStore(&sync.RWMutex{}, &data{
+		users:      map[string]user{},
+		sessions:   map[string]session{},
+		roles:      seedRoles(),
+		userRoles:  map[userRoleKey]struct{}{},
+		categories: map[string]category{},
+		products:   map[string]product{},
+		clients:    map[string]client{},
+		orders:     map[string]order{},
+		lines:      map[string]orderLine{},
+	}, false)
+}
+
+func newStore(mu *sync.RWMutex, d *data, tx bool) *Store {
+	s := &Store{
+		mu:   mu,
+		data: d,
+		tx:   tx,
+	}
+
+	s.product = &productRepo{s: s}
+	s.category = &categoryRepo{s: s}
+	s.client = &clientRepo{s: s}
+	s.order = &orderRepo{s: s}
+	s.user = &userRepo{s: s}
+	s.session = &sessionRepo{s: s}
+	s.role = &roleRepo{s: s}
+
+	return s
+}
+
+// WithTx runs fn with a store working on a copy of the data, which replaces
+// the data when fn returns nil. Calling WithTx on the store passed to fn
+// nests the same way, like a savepoint. Other callers wait until fn
+// returns, so fn must not use the outer store.
+func (s *Store) WithTx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+	if !s.tx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	tx := newStore(s.mu, s.data.clone(), true)
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	*s.data = *tx.data
+
+	return nil
+}
+
+func (s *Store) CloseDB() {}
+
+// Ping always succeeds, the data has no schema to migrate.
+func (s *Store) Ping(ctx context.Context) (*models.DatabaseHealth, error) {
+	return &models.DatabaseHealth{}, nil
+}
+
+func (s *Store) User() storage.UserRepoI {
+	return s.user
+}
+
+func (s *Store) Session() storage.SessionRepoI {
+	return s.session
+}
+
+func (s *Store) Role() storage.RoleRepoI {
+	return s.role
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return s.product
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return s.category
+}
+
+func (s *Store) Client() storage.ClientRepoI {
+	return s.client
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+	return s.order
+}
+
+// read runs fn on the current data, fn must not change it.
+func (s *Store) read(fn func(d *data) error) error {
+	if !s.tx {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	return fn(s.data)
+}
+
+// write runs fn on a copy of the data which replaces the data when fn
+// returns nil.
+func (s *Store) write(fn func(d *data) error) error {
+	if !s.tx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	next := s.data.clone()
+
+	err := fn(next)
+	if err != nil {
+		return err
+	}
+
+	*s.data = *next
+
+	return nil
+}
+
+func (d *data) clone() *data {
+	c := &data{
+		seq:        d.seq,
+		users:      make(map[string]user, len(d.users)),
+		sessions:   make(map[string]session, len(d.sessions)),
+		roles:      d.roles,
+		userRoles:  make(map[userRoleKey]struct{}, len(d.userRoles)),
+		categories: make(map[string]category, len(d.categories)),
+		products:   make(map[string]product, len(d.products)),
+		clients:    make(map[string]client, len(d.clients)),
+		orders:     make(map[string]order, len(d.orders)),
+		lines:      make(map[string]orderLine, len(d.lines)),
+		history:    append([]orderHistory(nil), d.history...),
+	}
+
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.userRoles {
+		c.userRoles[k] = v
+	}
+	for k, v := range d.categories {
+		c.categories[k] = v
+	}
+	for k, v := range d.products {
+		c.products[k] = v
+	}
+	for k, v := range d.clients {
+		c.clients[k] = v
+	}
+	for k, v := range d.orders {
+		c.orders[k] = v
+	}
+	for k, v := range d.lines {
+		c.lines[k] = v
+	}
+
+	return c
+}
+
+// nextSeq numbers the rows in insertion order, which is the order lists
+// without an ORDER BY come back in.
+func (d *data) nextSeq() int64 {
+	d.seq++
+	return d.seq
+}
+
+// row is the bookkeeping every table shares.
+type row struct {
+	seq       int64
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt time.Time
+	version   int
+}
+
+func newRow(d *data, at time.Time) row {
+	return row{
+		seq:       d.nextSeq(),
+		createdAt: at,
+		updatedAt: at,
+		version:   1,
+	}
+}
+
+func (r row) live() bool {
+	return r.deletedAt.IsZero()
+}
+
+// visible tells whether a lookup that may include deleted rows sees r.
+func (r row) visible(includeDeleted bool) bool {
+	return includeDeleted || r.live()
+}
+
+// touch is what every write a client asks for does to a row: the version
+// is bumped and updated_at moves to now.
+func (r *row) touch(at time.Time) {
+	r.updatedAt = at
+	r.version++
+}
+
+// refresh is what the writes that keep stock and totals up to date do to
+// a row, they leave the version alone so clients' tags stay valid.
+func (r *row) refresh(at time.Time) {
+	r.updatedAt = at
+}
+
+// softDelete marks r deleted, which does not move updated_at.
+func (r *row) softDelete(at time.Time) {
+	r.deletedAt = at
+	r.version++
+}
+
+func (r *row) restore(at time.Time) {
+	r.deletedAt = time.Time{}
+	r.touch(at)
+}
+
+// matchesVersion tells whether a write expecting version may change r, 0
+// expects any version.
+func (r row) matchesVersion(version int) bool {
+	return version <= 0 || r.version == version
+}
+
+// purgeable tells whether r was deleted more than days days ago.
+func (r row) purgeable(days int, at time.Time) bool {
+	return !r.live() && r.deletedAt.Before(at.AddDate(0, 0, -days))
+}
+
+// now is the time of a statement at the precision of a Postgres timestamp.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// formatTime formats t the way CAST(timestamp AS VARCHAR) does, the zero
+// time stands for NULL.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05.999999")
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05.999999999", value)
+}
+
+// pageBounds returns the slice bounds of an OFFSET/LIMIT page of n rows,
+// 10 rows when no limit is given.
+func pageBounds(n, offset, limit int) (int, int) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	start := offset
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+
+	end := start + limit
+	if end > n {
+		end = n
+	}
+
+	return start, end
+}
+
+// keysetItem is the position of a row in a keyset page over (created_at,
+// id), newest first.
+type keysetItem struct {
+	index     int
+	createdAt time.Time
+	id        string
+}
+
+// keysetPage returns the indexes of the items after cursor, newest first,
+// with one item more than the page to tell whether there is a next page.
+func keysetPage(items []keysetItem, cursor string, limit int) ([]int, error) {
+	if len(cursor) > 0 {
+		createdAt, id, err := helper.DecodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		at, err := parseTime(createdAt)
+		if err != nil {
+			return nil, helper.ErrInvalidCursor
+		}
+
+		after := items[:0:0]
+		for _, item := range items {
+			if item.createdAt.Before(at) || (item.createdAt.Equal(at) && item.id < id) {
+				after = append(after, item)
+			}
+		}
+		items = after
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].createdAt.Equal(items[j].createdAt) {
+			return items[i].createdAt.After(items[j].createdAt)
+		}
+		return items[i].id > items[j].id
+	})
+
+	if len(items) > keysetLimit(limit)+1 {
+		items = items[:keysetLimit(limit)+1]
+	}
+
+	indexes := make([]int, 0, len(items))
+	for _, item := range items {
+		indexes = append(indexes, item.index)
+	}
+
+	return indexes, nil
+}
+
+// keysetLimit is the page size of a keyset page, the rows past it only
+// tell that there is a next page.
+func keysetLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	return limit
+}
+
+// foreignKey is the error of a write that refers to a missing row.
+func foreignKey(field, constraint string) error {
+	return &storage.Error{Err: storage.ErrForeignKey, Field: field, Constraint: constraint}
+}
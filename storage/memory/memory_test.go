@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"app/storage/storagetest"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return NewStore()
+	})
+}
+
+// TestStoreConcurrentReservations reserves the stock of one product from
+// many goroutines, only as many lines as there is stock may be added.
+func TestStoreConcurrentReservations(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		strg = NewStore()
+	)
+
+	clientId, err := strg.Client().Create(ctx, &models.CreateClient{FirstName: "Test", LastName: "Test", PhoneNumber: "+998933791110"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	categoryId, err := strg.Category().Create(ctx, &models.CreateCategory{Name: "Test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	productId, err := strg.Product().Create(ctx, &models.CreateProduct{Name: "Test", CategoryId: categoryId, Price: 1, Quantity: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		added int
+	)
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			orderId, err := strg.Order().Create(ctx, &models.CreateOrder{ClientId: clientId})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			_, err = strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: orderId, ProductId: productId, Quantity: 1})
+			if errors.Is(err, storage.ErrInsufficientStock) {
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			mu.Lock()
+			added++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	product, err := strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: productId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if added != 20 || product.Quantity != 0 {
+		t.Errorf("got: %d lines and %d in stock, expected: 20 lines and 0 in stock", added, product.Quantity)
+	}
+}
@@ -0,0 +1,661 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type order struct {
+	row
+	id       string
+	clientId string
+	price    float64
+	status   string
+}
+
+func (o order) model(d *data, withProducts bool) *models.Order {
+	c := d.clients[o.clientId]
+
+	resp := &models.Order{
+		Id:       o.id,
+		ClientId: o.clientId,
+		ClientData: &models.Client{
+			Id:          c.id,
+			FirstName:   c.firstName,
+			LastName:    c.lastName,
+			PhoneNumber: c.phoneNumber,
+			CreatedAt:   formatTime(c.createdAt),
+			UpdatedAt:   formatTime(c.updatedAt),
+		},
+		Price:     o.price,
+		Status:    o.status,
+		CreatedAt: formatTime(o.createdAt),
+		UpdatedAt: formatTime(o.updatedAt),
+		Version:   o.version,
+		DeletedAt: formatTime(o.deletedAt),
+	}
+
+	if withProducts {
+		resp.OrderProducts = []*models.OrderProduct{}
+		for _, l := range orderLines(d, o.id) {
+			resp.OrderProducts = append(resp.OrderProducts, l.model(d))
+		}
+	}
+
+	return resp
+}
+
+type orderLine struct {
+	seq       int64
+	id        string
+	orderId   string
+	productId string
+	quantity  int
+	unitPrice float64
+	createdAt time.Time
+}
+
+func (l orderLine) model(d *data) *models.OrderProduct {
+	p := d.products[l.productId]
+	c := d.categories[p.categoryId]
+
+	return &models.OrderProduct{
+		Id:        l.id,
+		OrderId:   l.orderId,
+		ProductId: l.productId,
+		ProductData: &models.Product{
+			Id:         p.id,
+			Name:       p.name,
+			CategoryId: p.categoryId,
+			CategoryData: &models.Category{
+				Id:        c.id,
+				Name:      c.name,
+				CreatedAt: formatTime(c.createdAt),
+				UpdatedAt: formatTime(c.updatedAt),
+			},
+			Description: p.description,
+			Price:       p.price,
+			Quantity:    p.quantity,
+			CreatedAt:   formatTime(p.createdAt),
+			UpdatedAt:   formatTime(p.updatedAt),
+		},
+		Quantity:   l.quantity,
+		UnitPrice:  l.unitPrice,
+		TotalPrice: float64(l.quantity) * l.unitPrice,
+		CreatedAt:  formatTime(l.createdAt),
+	}
+}
+
+// orderLines returns the lines of the order in the order they were added.
+func orderLines(d *data, orderId string) []orderLine {
+	var lines []orderLine
+	for _, l := range d.lines {
+		if l.orderId == orderId {
+			lines = append(lines, l)
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].seq < lines[j].seq
+	})
+
+	return lines
+}
+
+type orderHistory struct {
+	id         string
+	orderId    string
+	fromStatus string
+	toStatus   string
+	fromPrice  float64
+	toPrice    float64
+	changedBy  string
+	reason     string
+	createdAt  time.Time
+}
+
+func (h orderHistory) model() *models.OrderHistory {
+	return &models.OrderHistory{
+		Id:         h.id,
+		OrderId:    h.orderId,
+		FromStatus: h.fromStatus,
+		ToStatus:   h.toStatus,
+		FromPrice:  h.fromPrice,
+		ToPrice:    h.toPrice,
+		ChangedBy:  h.changedBy,
+		Reason:     h.reason,
+		CreatedAt:  formatTime(h.createdAt),
+	}
+}
+
+type orderRepo struct {
+	s *Store
+}
+
+func checkClient(d *data, clientId string) error {
+	if _, ok := d.clients[clientId]; !ok {
+		return foreignKey("client_id", "orders_client_id_fkey")
+	}
+	return nil
+}
+
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		err := checkClient(d, req.ClientId)
+		if err != nil {
+			return err
+		}
+
+		d.orders[id] = order{
+			row:      newRow(d, now()),
+			id:       id,
+			clientId: req.ClientId,
+			status:   models.OrderStatusNew,
+		}
+
+		return addOrderHistory(d, &models.CreateOrderHistory{
+			OrderId:   id,
+			ToStatus:  models.OrderStatusNew,
+			ChangedBy: req.CreatedBy,
+			Reason:    "order created",
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
+	var resp *models.Order
+
+	err := r.s.read(func(d *data) error {
+		o, ok := d.orders[req.Id]
+		if !ok || !o.visible(req.IncludeDeleted) {
+			return storage.ErrNotFound
+		}
+
+		resp = o.model(d, true)
+
+		if req.WithHistory {
+			resp.History = orderHistoryOf(d, o.id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+	resp := &models.GetListOrderResponse{}
+
+	err := r.s.read(func(d *data) error {
+		var orders []order
+		for _, o := range d.orders {
+			if !o.visible(req.IncludeDeleted) {
+				continue
+			}
+
+			c := d.clients[o.clientId]
+			if !containsFold(req.Search, c.firstName, c.lastName, c.phoneNumber) {
+				continue
+			}
+
+			orders = append(orders, o)
+		}
+
+		var page []order
+		if req.Keyset {
+			items := make([]keysetItem, 0, len(orders))
+			for i, o := range orders {
+				items = append(items, keysetItem{index: i, createdAt: o.createdAt, id: o.id})
+			}
+
+			indexes, err := keysetPage(items, req.Cursor, req.Limit)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range indexes {
+				page = append(page, orders[i])
+			}
+		} else {
+			sort.Slice(orders, func(i, j int) bool {
+				return orders[i].seq < orders[j].seq
+			})
+
+			start, end := pageBounds(len(orders), req.Offset, req.Limit)
+			page = orders[start:end]
+
+			if len(page) > 0 {
+				resp.Count = len(orders)
+			}
+		}
+
+		for _, o := range page {
+			resp.Orders = append(resp.Orders, o.model(d, req.WithProducts))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Keyset && len(resp.Orders) > keysetLimit(req.Limit) {
+		resp.Orders = resp.Orders[:keysetLimit(req.Limit)]
+
+		last := resp.Orders[len(resp.Orders)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return resp, nil
+}
+
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	return r.patch(&models.PatchOrder{
+		Id:       req.Id,
+		ClientId: &req.ClientId,
+		Version:  req.Version,
+	})
+}
+
+func (r *orderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	return r.patch(req)
+}
+
+func (r *orderRepo) patch(req *models.PatchOrder) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		o, ok := d.orders[req.Id]
+		if !ok || !o.live() {
+			return nil
+		}
+
+		if !o.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		if req.ClientId != nil {
+			err := checkClient(d, *req.ClientId)
+			if err != nil {
+				return err
+			}
+			o.clientId = *req.ClientId
+		}
+		o.touch(now())
+
+		d.orders[o.id] = o
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// UpdateStatus moves the order to req.Status when the transition is allowed
+// and applies its side effects along with it.
+func (r *orderRepo) UpdateStatus(ctx context.Context, req *models.UpdateOrderStatus) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		o, ok := d.orders[req.Id]
+		if !ok || !o.live() {
+			return nil
+		}
+
+		if !models.CanTransitionOrderStatus(o.status, req.Status) {
+			return storage.ErrInvalidTransition
+		}
+
+		status := o.status
+
+		o.status = req.Status
+		o.touch(now())
+
+		d.orders[o.id] = o
+		rows = 1
+
+		err := addOrderHistory(d, &models.CreateOrderHistory{
+			OrderId:    o.id,
+			FromStatus: status,
+			ToStatus:   req.Status,
+			FromPrice:  o.price,
+			ToPrice:    o.price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     req.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		// goods of cancelled orders, and of orders refunded before they
+		// were shipped, go back to the stock
+		if req.Status == models.OrderStatusCancelled ||
+			(req.Status == models.OrderStatusRefunded && status == models.OrderStatusPaid) {
+			for _, l := range orderLines(d, o.id) {
+				changeStock(d, l.productId, l.quantity)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		o, ok := d.orders[req.Id]
+		if !ok || !o.live() {
+			return nil
+		}
+
+		if !o.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		o.softDelete(now())
+
+		d.orders[o.id] = o
+		rows = 1
+
+		// the goods the order still holds go back to the stock
+		if models.OrderHoldsStock(o.status) {
+			for _, l := range orderLines(d, o.id) {
+				changeStock(d, l.productId, l.quantity)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		o, ok := d.orders[req.Id]
+		if !ok || o.live() {
+			return nil
+		}
+
+		o.restore(now())
+
+		d.orders[o.id] = o
+		rows = 1
+
+		if !models.OrderHoldsStock(o.status) {
+			return nil
+		}
+
+		// reserve the goods Delete released again, all or nothing
+		wanted := map[string]int{}
+		for _, l := range orderLines(d, o.id) {
+			wanted[l.productId] += l.quantity
+		}
+
+		for productId, quantity := range wanted {
+			if d.products[productId].quantity < quantity {
+				return storage.ErrInsufficientStock
+			}
+		}
+
+		for productId, quantity := range wanted {
+			changeStock(d, productId, -quantity)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Purge removes the orders deleted more than req.Days days ago together
+// with their lines and status history. Delete released their goods
+// already.
+func (r *orderRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		purged := map[string]bool{}
+		for id, o := range d.orders {
+			if o.purgeable(req.Days, at) {
+				purged[id] = true
+				delete(d.orders, id)
+				rows++
+			}
+		}
+
+		for id, l := range d.lines {
+			if purged[l.orderId] {
+				delete(d.lines, id)
+			}
+		}
+
+		history := d.history[:0]
+		for _, h := range d.history {
+			if !purged[h.orderId] {
+				history = append(history, h)
+			}
+		}
+		d.history = history
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *orderRepo) GetHistory(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetListOrderHistoryResponse, error) {
+	resp := &models.GetListOrderHistoryResponse{}
+
+	err := r.s.read(func(d *data) error {
+		resp.History = orderHistoryOf(d, req.Id)
+		resp.Count = len(resp.History)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// orderHistoryOf returns the history of the order, oldest first.
+func orderHistoryOf(d *data, orderId string) []*models.OrderHistory {
+	var history []*models.OrderHistory
+	for _, h := range d.history {
+		if h.orderId == orderId {
+			history = append(history, h.model())
+		}
+	}
+	return history
+}
+
+// -------------ORDER_PRODUCTS-----------------------------------------------------------------------------------------------
+func (r *orderRepo) AddOrderProduct(ctx context.Context, req *models.CreateOrderItem) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		o, ok := d.orders[req.OrderId]
+		if !ok || !o.live() {
+			return storage.ErrNotFound
+		}
+
+		if o.status != models.OrderStatusNew {
+			return storage.ErrOrderFrozen
+		}
+
+		p, ok := d.products[req.ProductId]
+		if !ok || !p.live() {
+			return storage.ErrNotFound
+		}
+
+		if p.quantity < req.Quantity {
+			return storage.ErrInsufficientStock
+		}
+
+		if req.Quantity <= 0 {
+			return &storage.Error{Err: storage.ErrCheckViolation, Field: "quantity", Constraint: "order_products_quantity_check"}
+		}
+
+		changeStock(d, p.id, -req.Quantity)
+
+		d.lines[id] = orderLine{
+			seq:       d.nextSeq(),
+			id:        id,
+			orderId:   o.id,
+			productId: p.id,
+			quantity:  req.Quantity,
+			unitPrice: p.price,
+			createdAt: now(),
+		}
+
+		return recalculateOrderPrice(d, &models.CreateOrderHistory{
+			OrderId:    o.id,
+			FromStatus: o.status,
+			ToStatus:   o.status,
+			FromPrice:  o.price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     "order line added",
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *orderRepo) RemoveOrderItem(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		l, ok := d.lines[req.Id]
+		if !ok {
+			return nil
+		}
+
+		o, ok := d.orders[l.orderId]
+		if !ok || !o.live() {
+			return nil
+		}
+
+		if o.status != models.OrderStatusNew {
+			return storage.ErrOrderFrozen
+		}
+
+		delete(d.lines, l.id)
+		rows = 1
+
+		changeStock(d, l.productId, l.quantity)
+
+		return recalculateOrderPrice(d, &models.CreateOrderHistory{
+			OrderId:    o.id,
+			FromStatus: o.status,
+			ToStatus:   o.status,
+			FromPrice:  o.price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     "order line removed",
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// changeStock adds delta to the quantity of the product.
+func changeStock(d *data, productId string, delta int) {
+	p, ok := d.products[productId]
+	if !ok {
+		return
+	}
+
+	p.quantity += delta
+	p.refresh(now())
+
+	d.products[productId] = p
+}
+
+// recalculateOrderPrice sets the order total to the sum of its lines and
+// records the change in the order history
+func recalculateOrderPrice(d *data, history *models.CreateOrderHistory) error {
+	o := d.orders[history.OrderId]
+
+	o.price = 0
+	for _, l := range orderLines(d, o.id) {
+		o.price += float64(l.quantity) * l.unitPrice
+	}
+	o.refresh(now())
+
+	d.orders[o.id] = o
+
+	history.ToPrice = o.price
+	if history.FromPrice == history.ToPrice {
+		return nil
+	}
+
+	return addOrderHistory(d, history)
+}
+
+func addOrderHistory(d *data, req *models.CreateOrderHistory) error {
+	if len(req.ChangedBy) > 0 {
+		if _, ok := d.users[req.ChangedBy]; !ok {
+			return foreignKey("changed_by", "order_status_history_changed_by_fkey")
+		}
+	}
+
+	d.history = append(d.history, orderHistory{
+		id:         uuid.NewString(),
+		orderId:    req.OrderId,
+		fromStatus: req.FromStatus,
+		toStatus:   req.ToStatus,
+		fromPrice:  req.FromPrice,
+		toPrice:    req.ToPrice,
+		changedBy:  req.ChangedBy,
+		reason:     req.Reason,
+		createdAt:  now(),
+	})
+
+	return nil
+}
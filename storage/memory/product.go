@@ -0,0 +1,452 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"app/storage"
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type product struct {
+	row
+	id          string
+	name        string
+	categoryId  string
+	description string
+	price       float64
+	quantity    int
+}
+
+func (p product) model(d *data) *models.Product {
+	c := d.categories[p.categoryId]
+
+	return &models.Product{
+		Id:         p.id,
+		Name:       p.name,
+		CategoryId: p.categoryId,
+		CategoryData: &models.Category{
+			Id:        c.id,
+			Name:      c.name,
+			CreatedAt: formatTime(c.createdAt),
+			UpdatedAt: formatTime(c.updatedAt),
+		},
+		Description: p.description,
+		Price:       p.price,
+		Quantity:    p.quantity,
+		CreatedAt:   formatTime(p.createdAt),
+		UpdatedAt:   formatTime(p.updatedAt),
+		Version:     p.version,
+		DeletedAt:   formatTime(p.deletedAt),
+	}
+}
+
+type productRepo struct {
+	s *Store
+}
+
+func checkCategory(d *data, categoryId string) error {
+	if _, ok := d.categories[categoryId]; !ok {
+		return foreignKey("category_id", "product_category_id_fkey")
+	}
+	return nil
+}
+
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		err := checkCategory(d, req.CategoryId)
+		if err != nil {
+			return err
+		}
+
+		d.products[id] = product{
+			row:         newRow(d, now()),
+			id:          id,
+			name:        req.Name,
+			categoryId:  req.CategoryId,
+			description: req.Description,
+			price:       req.Price,
+			quantity:    req.Quantity,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error) {
+	var resp *models.Product
+
+	err := r.s.read(func(d *data) error {
+		p, ok := d.products[req.Id]
+		if !ok || !p.visible(req.IncludeDeleted) {
+			return storage.ErrNotFound
+		}
+
+		resp = p.model(d)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// productMatch is a product of a list with its search rank.
+type productMatch struct {
+	product
+	rank float64
+}
+
+func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+	resp := &models.GetListProductResponse{}
+
+	err := r.s.read(func(d *data) error {
+		createdFrom, createdTo, err := timeRange(req.CreatedFrom, req.CreatedTo)
+		if err != nil {
+			return err
+		}
+
+		categories := categorySet(d, req.CategoryIds, req.IncludeDescendants)
+		query := parseTextQuery(req.Search)
+
+		var matches []productMatch
+		for _, p := range d.products {
+			if !p.visible(req.IncludeDeleted) {
+				continue
+			}
+			if categories != nil && !categories[p.categoryId] {
+				continue
+			}
+			if req.MinPrice != nil && p.price < *req.MinPrice {
+				continue
+			}
+			if req.MaxPrice != nil && p.price > *req.MaxPrice {
+				continue
+			}
+			if req.InStock && p.quantity <= 0 {
+				continue
+			}
+			if (!createdFrom.IsZero() && p.createdAt.Before(createdFrom)) || (!createdTo.IsZero() && p.createdAt.After(createdTo)) {
+				continue
+			}
+
+			var rank float64
+			if len(req.Search) > 0 {
+				// name weighs A and description B, as in the search vector
+				rank = query.rank([]string{p.name, p.description}, []float64{1, 0.4})
+				if rank <= 0 {
+					continue
+				}
+			}
+
+			matches = append(matches, productMatch{product: p, rank: rank})
+		}
+
+		var page []productMatch
+		if req.Keyset {
+			items := make([]keysetItem, 0, len(matches))
+			for i, m := range matches {
+				items = append(items, keysetItem{index: i, createdAt: m.createdAt, id: m.id})
+			}
+
+			indexes, err := keysetPage(items, req.Cursor, req.Limit)
+			if err != nil {
+				return err
+			}
+
+			for _, i := range indexes {
+				page = append(page, matches[i])
+			}
+		} else {
+			sortProducts(matches, req.SortBy, req.Order, len(req.Search) > 0)
+
+			start, end := pageBounds(len(matches), req.Offset, req.Limit)
+			page = matches[start:end]
+
+			if len(page) > 0 {
+				resp.Count = len(matches)
+			}
+		}
+
+		for _, m := range page {
+			item := m.model(d)
+			item.Rank = m.rank
+			if len(req.Search) > 0 {
+				item.Highlight = query.highlight(m.name + " " + m.description)
+			}
+
+			resp.Products = append(resp.Products, item)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Keyset && len(resp.Products) > keysetLimit(req.Limit) {
+		resp.Products = resp.Products[:keysetLimit(req.Limit)]
+
+		last := resp.Products[len(resp.Products)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return resp, nil
+}
+
+// sortProducts orders matches by the sort field, created_at newest first
+// or the best matches first when it is not one of
+// models.ProductSortFields. Ties are ordered by id.
+func sortProducts(matches []productMatch, sortBy, order string, search bool) {
+	less := map[string]func(a, b productMatch) int{
+		"name":       func(a, b productMatch) int { return strings.Compare(a.name, b.name) },
+		"price":      func(a, b productMatch) int { return compareFloat(a.price, b.price) },
+		"quantity":   func(a, b productMatch) int { return compareFloat(float64(a.quantity), float64(b.quantity)) },
+		"created_at": func(a, b productMatch) int { return compareTime(a.createdAt, b.createdAt) },
+	}
+
+	compare, ok := less[sortBy]
+	if !ok {
+		compare, order = less["created_at"], "desc"
+		if search {
+			compare = func(a, b productMatch) int { return compareFloat(a.rank, b.rank) }
+		}
+	}
+
+	desc := strings.EqualFold(order, "desc")
+
+	sort.Slice(matches, func(i, j int) bool {
+		c := compare(matches[i], matches[j])
+		if c == 0 {
+			return matches[i].id < matches[j].id
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// categorySet returns the categories a product list is narrowed to, nil
+// for all of them. Descendants are followed through live categories only.
+func categorySet(d *data, categoryIds []string, includeDescendants bool) map[string]bool {
+	if len(categoryIds) == 0 {
+		return nil
+	}
+
+	set := map[string]bool{}
+	for _, id := range categoryIds {
+		set[id] = true
+	}
+
+	for added := includeDescendants; added; {
+		added = false
+		for _, c := range d.categories {
+			if c.live() && set[c.parentId] && !set[c.id] {
+				set[c.id] = true
+				added = true
+			}
+		}
+	}
+
+	return set
+}
+
+// timeRange parses the bounds of a created_at range, zero times leave the
+// range open.
+func timeRange(from, to string) (time.Time, time.Time, error) {
+	var (
+		fromTime, toTime time.Time
+		err              error
+	)
+
+	if len(from) > 0 {
+		fromTime, err = parseTime(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	if len(to) > 0 {
+		toTime, err = parseTime(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+
+	return fromTime, toTime, nil
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	return r.patch(&models.PatchProduct{
+		Id:          req.Id,
+		Name:        &req.Name,
+		CategoryId:  &req.CategoryId,
+		Description: &req.Description,
+		Price:       &req.Price,
+		Quantity:    &req.Quantity,
+		Version:     req.Version,
+	})
+}
+
+func (r *productRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+	return r.patch(req)
+}
+
+func (r *productRepo) patch(req *models.PatchProduct) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		p, ok := d.products[req.Id]
+		if !ok || !p.live() {
+			return nil
+		}
+
+		if !p.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		if req.Name != nil {
+			p.name = *req.Name
+		}
+		if req.CategoryId != nil {
+			err := checkCategory(d, *req.CategoryId)
+			if err != nil {
+				return err
+			}
+			p.categoryId = *req.CategoryId
+		}
+		if req.Description != nil {
+			p.description = *req.Description
+		}
+		if req.Price != nil {
+			p.price = *req.Price
+		}
+		if req.Quantity != nil {
+			p.quantity = *req.Quantity
+		}
+		p.touch(now())
+
+		d.products[p.id] = p
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		p, ok := d.products[req.Id]
+		if !ok || !p.live() {
+			return nil
+		}
+
+		if !p.matchesVersion(req.Version) {
+			return storage.ErrVersionMismatch
+		}
+
+		p.softDelete(now())
+
+		d.products[p.id] = p
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		p, ok := d.products[req.Id]
+		if !ok || p.live() {
+			return nil
+		}
+
+		p.restore(now())
+
+		d.products[p.id] = p
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Purge removes the products deleted more than req.Days days ago that no
+// order line refers to any more.
+func (r *productRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		referenced := map[string]bool{}
+		for _, l := range d.lines {
+			referenced[l.productId] = true
+		}
+
+		for id, p := range d.products {
+			if p.purgeable(req.Days, at) && !referenced[id] {
+				delete(d.products, id)
+				rows++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"sort"
+	"time"
+)
+
+type role struct {
+	id          string
+	name        string
+	description string
+	permissions []string
+	createdAt   time.Time
+}
+
+func (r role) model() *models.Role {
+	return &models.Role{
+		Id:          r.id,
+		Name:        r.name,
+		Description: r.description,
+		Permissions: append([]string{}, r.permissions...),
+		CreatedAt:   formatTime(r.createdAt),
+	}
+}
+
+type userRoleKey struct {
+	userId string
+	roleId string
+}
+
+// seedRoles returns the roles and permissions the roles migration
+// creates, they never change afterwards.
+func seedRoles() map[string]role {
+	at := now()
+
+	managerPermissions := []string{
+		models.PermissionUserRead, models.PermissionRoleRead, models.PermissionCategoryWrite,
+		models.PermissionProductWrite, models.PermissionProductDelete, models.PermissionClientWrite,
+		models.PermissionClientDelete, models.PermissionOrderCreate, models.PermissionOrderWrite,
+		models.PermissionOrderDelete,
+	}
+
+	cashierPermissions := []string{
+		models.PermissionClientWrite, models.PermissionOrderCreate, models.PermissionOrderWrite,
+	}
+
+	adminPermissions := append([]string{
+		models.PermissionUserWrite, models.PermissionUserDelete, models.PermissionRoleAssign,
+		models.PermissionProductPrice,
+	}, managerPermissions...)
+
+	roles := []role{
+		{id: "8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a01", name: models.RoleAdmin, description: "Full access", permissions: adminPermissions},
+		{id: "8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a02", name: models.RoleManager, description: "Manages catalog, clients and orders", permissions: managerPermissions},
+		{id: "8b1f1c52-52a4-4c43-9d8e-3f0b7f0c0a03", name: models.RoleCashier, description: "Serves clients and creates orders", permissions: cashierPermissions},
+	}
+
+	seeded := make(map[string]role, len(roles))
+	for _, r := range roles {
+		sort.Strings(r.permissions)
+		r.createdAt = at
+		seeded[r.id] = r
+	}
+
+	return seeded
+}
+
+type roleRepo struct {
+	s *Store
+}
+
+func roleByName(d *data, name string) (role, bool) {
+	for _, r := range d.roles {
+		if r.name == name {
+			return r, true
+		}
+	}
+	return role{}, false
+}
+
+func (r *roleRepo) GetByID(ctx context.Context, req *models.RolePrimaryKey) (*models.Role, error) {
+	var resp *models.Role
+
+	err := r.s.read(func(d *data) error {
+		found, ok := d.roles[req.Id]
+		if len(req.Name) > 0 {
+			found, ok = roleByName(d, req.Name)
+		}
+
+		if !ok {
+			return storage.ErrNotFound
+		}
+
+		resp = found.model()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *roleRepo) GetList(ctx context.Context, req *models.GetListRoleRequest) (*models.GetListRoleResponse, error) {
+	resp := &models.GetListRoleResponse{}
+
+	err := r.s.read(func(d *data) error {
+		var roles []role
+		for _, found := range d.roles {
+			if containsFold(req.Search, found.name) {
+				roles = append(roles, found)
+			}
+		}
+
+		sort.Slice(roles, func(i, j int) bool {
+			return roles[i].name < roles[j].name
+		})
+
+		start, end := pageBounds(len(roles), req.Offset, req.Limit)
+		for _, found := range roles[start:end] {
+			resp.Roles = append(resp.Roles, found.model())
+		}
+
+		if len(resp.Roles) > 0 {
+			resp.Count = len(roles)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *roleRepo) GetUserRoles(ctx context.Context, req *models.UserPrimaryKey) (*models.UserRoles, error) {
+	resp := &models.UserRoles{
+		UserId:      req.Id,
+		Roles:       []string{},
+		Permissions: []string{},
+	}
+
+	err := r.s.read(func(d *data) error {
+		permissions := map[string]bool{}
+
+		for key := range d.userRoles {
+			if key.userId != req.Id {
+				continue
+			}
+
+			found := d.roles[key.roleId]
+			resp.Roles = append(resp.Roles, found.name)
+
+			for _, permission := range found.permissions {
+				if !permissions[permission] {
+					permissions[permission] = true
+					resp.Permissions = append(resp.Permissions, permission)
+				}
+			}
+		}
+
+		sort.Strings(resp.Roles)
+		sort.Strings(resp.Permissions)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *roleRepo) AssignRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		found, ok := roleByName(d, req.Role)
+		if !ok {
+			return nil
+		}
+
+		if _, ok := d.users[req.UserId]; !ok {
+			return foreignKey("user_id", "user_roles_user_id_fkey")
+		}
+
+		key := userRoleKey{userId: req.UserId, roleId: found.id}
+		if _, ok := d.userRoles[key]; ok {
+			return nil
+		}
+
+		d.userRoles[key] = struct{}{}
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *roleRepo) RevokeRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		found, ok := roleByName(d, req.Role)
+		if !ok {
+			return nil
+		}
+
+		key := userRoleKey{userId: req.UserId, roleId: found.id}
+		if _, ok := d.userRoles[key]; !ok {
+			return nil
+		}
+
+		delete(d.userRoles, key)
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
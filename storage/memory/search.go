@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"strings"
+	"unicode"
+)
+
+// containsFold is the ILIKE '%value%' of helper.QueryBuilder.Search.
+func containsFold(value string, fields ...string) bool {
+	if len(value) == 0 {
+		return true
+	}
+
+	value = strings.ToLower(value)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// textQuery is websearch_to_tsquery('simple', ...) for the needs of the
+// lists: words match whole words case-insensitively, "or" separates
+// alternatives, a leading "-" excludes a word and quoted phrases match
+// their words anywhere.
+type textQuery struct {
+	// alternatives are matched when every word of one of them is present
+	alternatives [][]string
+	excluded     []string
+}
+
+func parseTextQuery(search string) textQuery {
+	var (
+		query   textQuery
+		current []string
+	)
+
+	for _, field := range strings.Fields(search) {
+		if strings.EqualFold(field, "or") {
+			if len(current) > 0 {
+				query.alternatives = append(query.alternatives, current)
+				current = nil
+			}
+			continue
+		}
+
+		excluded := strings.HasPrefix(field, "-")
+
+		for _, word := range words(field) {
+			if excluded {
+				query.excluded = append(query.excluded, word)
+			} else {
+				current = append(current, word)
+			}
+		}
+	}
+
+	if len(current) > 0 {
+		query.alternatives = append(query.alternatives, current)
+	}
+
+	return query
+}
+
+// rank matches the query against the documents, whose words weigh the
+// given weights, and returns a ts_rank like score, 0 when they do not
+// match.
+func (q textQuery) rank(documents []string, weights []float64) float64 {
+	present := map[string]float64{}
+	for i, document := range documents {
+		for _, word := range words(document) {
+			if weights[i] > present[word] {
+				present[word] = weights[i]
+			}
+		}
+	}
+
+	for _, word := range q.excluded {
+		if _, ok := present[word]; ok {
+			return 0
+		}
+	}
+
+	var best float64
+	for _, alternative := range q.alternatives {
+		var score float64
+		for _, word := range alternative {
+			weight, ok := present[word]
+			if !ok {
+				score = 0
+				break
+			}
+			score += weight
+		}
+
+		if score > best {
+			best = score
+		}
+	}
+
+	return best
+}
+
+// highlight wraps the words of document that the query looks for in
+// <b></b>, like ts_headline with StartSel=<b>, StopSel=</b>.
+func (q textQuery) highlight(document string) string {
+	wanted := map[string]bool{}
+	for _, alternative := range q.alternatives {
+		for _, word := range alternative {
+			wanted[word] = true
+		}
+	}
+
+	var (
+		sb   strings.Builder
+		word strings.Builder
+	)
+
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if wanted[strings.ToLower(word.String())] {
+			sb.WriteString("<b>" + word.String() + "</b>")
+		} else {
+			sb.WriteString(word.String())
+		}
+		word.Reset()
+	}
+
+	for _, ch := range document {
+		if isWordRune(ch) {
+			word.WriteRune(ch)
+			continue
+		}
+		flush()
+		sb.WriteRune(ch)
+	}
+	flush()
+
+	return sb.String()
+}
+
+// words splits s into the lower case words the 'simple' configuration
+// indexes.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(ch rune) bool {
+		return !isWordRune(ch)
+	})
+}
+
+func isWordRune(ch rune) bool {
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type session struct {
+	id               string
+	userId           string
+	familyId         string
+	refreshTokenHash string
+	userAgent        string
+	ipAddress        string
+	expiresAt        time.Time
+	revokedAt        time.Time
+	replacedBy       string
+	createdAt        time.Time
+	updatedAt        time.Time
+}
+
+func (s session) model(at time.Time) *models.Session {
+	return &models.Session{
+		Id:         s.id,
+		UserId:     s.userId,
+		FamilyId:   s.familyId,
+		UserAgent:  s.userAgent,
+		IpAddress:  s.ipAddress,
+		ExpiresAt:  formatTime(s.expiresAt),
+		RevokedAt:  formatTime(s.revokedAt),
+		ReplacedBy: s.replacedBy,
+		Expired:    !s.expiresAt.After(at),
+		Revoked:    !s.revokedAt.IsZero(),
+		CreatedAt:  formatTime(s.createdAt),
+		UpdatedAt:  formatTime(s.updatedAt),
+	}
+}
+
+type sessionRepo struct {
+	s *Store
+}
+
+func (r *sessionRepo) Create(ctx context.Context, req *models.CreateSession) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		if _, ok := d.users[req.UserId]; !ok {
+			return foreignKey("user_id", "user_sessions_user_id_fkey")
+		}
+
+		for _, s := range d.sessions {
+			if s.refreshTokenHash == req.RefreshTokenHash {
+				return &storage.Error{Err: storage.ErrConflict, Field: "refresh_token_hash", Constraint: "user_sessions_refresh_token_hash_key"}
+			}
+		}
+
+		at := now()
+
+		d.sessions[id] = session{
+			id:               id,
+			userId:           req.UserId,
+			familyId:         req.FamilyId,
+			refreshTokenHash: req.RefreshTokenHash,
+			userAgent:        req.UserAgent,
+			ipAddress:        req.IpAddress,
+			expiresAt:        at.Add(time.Duration(int64(req.TTL.Seconds())) * time.Second),
+			createdAt:        at,
+			updatedAt:        at,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *sessionRepo) GetByID(ctx context.Context, req *models.SessionPrimaryKey) (*models.Session, error) {
+	var resp *models.Session
+
+	err := r.s.read(func(d *data) error {
+		s, ok := d.sessions[req.Id]
+
+		if len(req.RefreshTokenHash) > 0 {
+			ok = false
+			for _, candidate := range d.sessions {
+				if candidate.refreshTokenHash == req.RefreshTokenHash {
+					s, ok = candidate, true
+					break
+				}
+			}
+		}
+
+		if !ok {
+			return storage.ErrNotFound
+		}
+
+		resp = s.model(now())
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Revoke revokes a single active session. Zero rows affected means the
+// session was already revoked, which callers treat as token reuse.
+func (r *sessionRepo) Revoke(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	return r.revoke(func(s session) bool {
+		return s.id == req.Id
+	}, req.ReplacedBy)
+}
+
+func (r *sessionRepo) RevokeFamily(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	return r.revoke(func(s session) bool {
+		return s.familyId == req.FamilyId
+	}, "")
+}
+
+func (r *sessionRepo) RevokeAll(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	return r.revoke(func(s session) bool {
+		return s.userId == req.UserId
+	}, "")
+}
+
+// revoke revokes the active sessions that match.
+func (r *sessionRepo) revoke(match func(s session) bool, replacedBy string) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		for id, s := range d.sessions {
+			if !s.revokedAt.IsZero() || !match(s) {
+				continue
+			}
+
+			s.revokedAt = at
+			s.updatedAt = at
+			if len(replacedBy) > 0 {
+				s.replacedBy = replacedBy
+			}
+
+			d.sessions[id] = s
+			rows++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
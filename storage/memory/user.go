@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+type user struct {
+	row
+	id          string
+	firstName   string
+	lastName    string
+	login       string
+	password    string
+	phoneNumber string
+}
+
+func (u user) model() *models.User {
+	return &models.User{
+		Id:          u.id,
+		FirstName:   u.firstName,
+		LastName:    u.lastName,
+		Login:       u.login,
+		PhoneNumber: u.phoneNumber,
+		CreatedAt:   formatTime(u.createdAt),
+		UpdatedAt:   formatTime(u.updatedAt),
+		DeletedAt:   formatTime(u.deletedAt),
+	}
+}
+
+type userRepo struct {
+	s *Store
+}
+
+// checkLogin enforces the unique login of live users, deleted users give
+// theirs up.
+func checkLogin(d *data, id, login string) error {
+	for _, u := range d.users {
+		if u.login == login && u.id != id && u.live() {
+			return &storage.Error{Err: storage.ErrConflict, Field: "login", Constraint: "users_login_live_key"}
+		}
+	}
+	return nil
+}
+
+func (r *userRepo) Create(ctx context.Context, req *models.CreateUser) (string, error) {
+	id := uuid.NewString()
+
+	err := r.s.write(func(d *data) error {
+		err := checkLogin(d, id, req.Login)
+		if err != nil {
+			return err
+		}
+
+		d.users[id] = user{
+			row:         newRow(d, now()),
+			id:          id,
+			firstName:   req.FirstName,
+			lastName:    req.LastName,
+			login:       req.Login,
+			password:    req.Password,
+			phoneNumber: req.PhoneNumber,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *userRepo) GetByID(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error) {
+	var resp *models.User
+
+	err := r.s.read(func(d *data) error {
+		if len(req.Login) > 0 {
+			u, ok := liveUserByLogin(d, req.Login)
+			if !ok {
+				return storage.ErrNotFound
+			}
+			req.Id = u.id
+		}
+
+		u, ok := d.users[req.Id]
+		if !ok || !u.visible(req.IncludeDeleted) {
+			return storage.ErrNotFound
+		}
+
+		resp = u.model()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func liveUserByLogin(d *data, login string) (user, bool) {
+	for _, u := range d.users {
+		if u.login == login && u.live() {
+			return u, true
+		}
+	}
+	return user{}, false
+}
+
+func (r *userRepo) GetCredentials(ctx context.Context, req *models.UserPrimaryKey) (*models.UserCredentials, error) {
+	var resp *models.UserCredentials
+
+	err := r.s.read(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if len(req.Login) > 0 {
+			u, ok = liveUserByLogin(d, req.Login)
+		}
+
+		if !ok || !u.live() {
+			return storage.ErrNotFound
+		}
+
+		resp = &models.UserCredentials{
+			Id:       u.id,
+			Login:    u.login,
+			Password: u.password,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest) (*models.GetListUserResponse, error) {
+	resp := &models.GetListUserResponse{}
+
+	err := r.s.read(func(d *data) error {
+		var users []user
+		for _, u := range d.users {
+			if !u.visible(req.IncludeDeleted) {
+				continue
+			}
+
+			if !containsFold(req.Search, u.firstName, u.lastName, u.login, u.phoneNumber) {
+				continue
+			}
+
+			users = append(users, u)
+		}
+
+		sort.Slice(users, func(i, j int) bool {
+			return users[i].seq < users[j].seq
+		})
+
+		start, end := pageBounds(len(users), req.Offset, req.Limit)
+		for _, u := range users[start:end] {
+			resp.Users = append(resp.Users, u.model())
+		}
+
+		// the count is a window over the returned rows
+		if len(resp.Users) > 0 {
+			resp.Count = len(users)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *userRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if !ok || !u.live() {
+			return nil
+		}
+
+		err := checkLogin(d, u.id, req.Login)
+		if err != nil {
+			return err
+		}
+
+		u.firstName = req.FirstName
+		u.lastName = req.LastName
+		u.login = req.Login
+		if len(req.Password) > 0 {
+			u.password = req.Password
+		}
+		u.phoneNumber = req.PhoneNumber
+		u.touch(now())
+
+		d.users[u.id] = u
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *userRepo) Patch(ctx context.Context, req *models.PatchUser) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if !ok || !u.live() {
+			return nil
+		}
+
+		if req.FirstName != nil {
+			u.firstName = *req.FirstName
+		}
+		if req.LastName != nil {
+			u.lastName = *req.LastName
+		}
+		if req.Login != nil {
+			err := checkLogin(d, u.id, *req.Login)
+			if err != nil {
+				return err
+			}
+			u.login = *req.Login
+		}
+		if req.Password != nil {
+			u.password = *req.Password
+		}
+		if req.PhoneNumber != nil {
+			u.phoneNumber = *req.PhoneNumber
+		}
+		u.touch(now())
+
+		d.users[u.id] = u
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, req *models.UpdateUserPassword) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if !ok || !u.live() {
+			return nil
+		}
+
+		u.password = req.Password
+		u.touch(now())
+
+		d.users[u.id] = u
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *userRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if !ok || !u.live() {
+			return nil
+		}
+
+		u.softDelete(now())
+
+		d.users[u.id] = u
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+func (r *userRepo) Restore(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		u, ok := d.users[req.Id]
+		if !ok || u.live() {
+			return nil
+		}
+
+		err := checkLogin(d, u.id, u.login)
+		if err != nil {
+			return err
+		}
+
+		u.restore(now())
+
+		d.users[u.id] = u
+		rows = 1
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// Purge removes the users deleted more than req.Days days ago, their
+// sessions and roles go with them and their order history entries lose
+// the author.
+func (r *userRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	var rows int64
+
+	err := r.s.write(func(d *data) error {
+		at := now()
+
+		for id, u := range d.users {
+			if !u.purgeable(req.Days, at) {
+				continue
+			}
+
+			delete(d.users, id)
+			rows++
+
+			for sessionId, s := range d.sessions {
+				if s.userId == id {
+					delete(d.sessions, sessionId)
+				}
+			}
+
+			for key := range d.userRoles {
+				if key.userId == id {
+					delete(d.userRoles, key)
+				}
+			}
+
+			for i := range d.history {
+				if d.history[i].changedBy == id {
+					d.history[i].changedBy = ""
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
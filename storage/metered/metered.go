@@ -0,0 +1,390 @@
+// Package metered times the repository methods of a storage, every call is
+// reported to the metrics by repository and method.
+package metered
+
+import (
+	"app/api/models"
+	"app/pkg/metrics"
+	"app/storage"
+	"context"
+	"time"
+)
+
+type Store struct {
+	next    storage.StorageI
+	metrics *metrics.Metrics
+}
+
+func New(next storage.StorageI, m *metrics.Metrics) *Store {
+	return &Store{
+		next:    next,
+		metrics: m,
+	}
+}
+
+// WithTx times the repositories of the transaction as well.
+func (s *Store) WithTx(ctx context.Context, fn func(tx storage.StorageI) error) error {
+	return s.next.WithTx(ctx, func(tx storage.StorageI) error {
+		return fn(New(tx, s.metrics))
+	})
+}
+
+func (s *Store) CloseDB() {
+	s.next.CloseDB()
+}
+
+func (s *Store) Ping(ctx context.Context) (*models.DatabaseHealth, error) {
+	return s.next.Ping(ctx)
+}
+
+func (s *Store) User() storage.UserRepoI {
+	return &userRepo{next: s.next.User(), metrics: s.metrics}
+}
+
+func (s *Store) Session() storage.SessionRepoI {
+	return &sessionRepo{next: s.next.Session(), metrics: s.metrics}
+}
+
+func (s *Store) Role() storage.RoleRepoI {
+	return &roleRepo{next: s.next.Role(), metrics: s.metrics}
+}
+
+func (s *Store) Product() storage.ProductRepoI {
+	return &productRepo{next: s.next.Product(), metrics: s.metrics}
+}
+
+func (s *Store) Category() storage.CategoryRepoI {
+	return &categoryRepo{next: s.next.Category(), metrics: s.metrics}
+}
+
+func (s *Store) Client() storage.ClientRepoI {
+	return &clientRepo{next: s.next.Client(), metrics: s.metrics}
+}
+
+func (s *Store) Order() storage.OrderRepoI {
+	return &orderRepo{next: s.next.Order(), metrics: s.metrics}
+}
+
+func observe(m *metrics.Metrics, repo, method string, start time.Time) {
+	m.ObserveQuery(repo, method, time.Since(start))
+}
+
+type userRepo struct {
+	next    storage.UserRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *userRepo) Create(ctx context.Context, req *models.CreateUser) (string, error) {
+	defer observe(r.metrics, "user", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *userRepo) GetByID(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error) {
+	defer observe(r.metrics, "user", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *userRepo) GetCredentials(ctx context.Context, req *models.UserPrimaryKey) (*models.UserCredentials, error) {
+	defer observe(r.metrics, "user", "GetCredentials", time.Now())
+	return r.next.GetCredentials(ctx, req)
+}
+
+func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest) (*models.GetListUserResponse, error) {
+	defer observe(r.metrics, "user", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *userRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, error) {
+	defer observe(r.metrics, "user", "Update", time.Now())
+	return r.next.Update(ctx, req)
+}
+
+func (r *userRepo) Patch(ctx context.Context, req *models.PatchUser) (int64, error) {
+	defer observe(r.metrics, "user", "Patch", time.Now())
+	return r.next.Patch(ctx, req)
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, req *models.UpdateUserPassword) (int64, error) {
+	defer observe(r.metrics, "user", "UpdatePassword", time.Now())
+	return r.next.UpdatePassword(ctx, req)
+}
+
+func (r *userRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "user", "Delete", time.Now())
+	return r.next.Delete(ctx, req)
+}
+
+func (r *userRepo) Restore(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "user", "Restore", time.Now())
+	return r.next.Restore(ctx, req)
+}
+
+func (r *userRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	defer observe(r.metrics, "user", "Purge", time.Now())
+	return r.next.Purge(ctx, req)
+}
+
+type sessionRepo struct {
+	next    storage.SessionRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *sessionRepo) Create(ctx context.Context, req *models.CreateSession) (string, error) {
+	defer observe(r.metrics, "session", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *sessionRepo) GetByID(ctx context.Context, req *models.SessionPrimaryKey) (*models.Session, error) {
+	defer observe(r.metrics, "session", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *sessionRepo) Revoke(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	defer observe(r.metrics, "session", "Revoke", time.Now())
+	return r.next.Revoke(ctx, req)
+}
+
+func (r *sessionRepo) RevokeFamily(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	defer observe(r.metrics, "session", "RevokeFamily", time.Now())
+	return r.next.RevokeFamily(ctx, req)
+}
+
+func (r *sessionRepo) RevokeAll(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	defer observe(r.metrics, "session", "RevokeAll", time.Now())
+	return r.next.RevokeAll(ctx, req)
+}
+
+type roleRepo struct {
+	next    storage.RoleRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *roleRepo) GetByID(ctx context.Context, req *models.RolePrimaryKey) (*models.Role, error) {
+	defer observe(r.metrics, "role", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *roleRepo) GetList(ctx context.Context, req *models.GetListRoleRequest) (*models.GetListRoleResponse, error) {
+	defer observe(r.metrics, "role", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *roleRepo) GetUserRoles(ctx context.Context, req *models.UserPrimaryKey) (*models.UserRoles, error) {
+	defer observe(r.metrics, "role", "GetUserRoles", time.Now())
+	return r.next.GetUserRoles(ctx, req)
+}
+
+func (r *roleRepo) AssignRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	defer observe(r.metrics, "role", "AssignRole", time.Now())
+	return r.next.AssignRole(ctx, req)
+}
+
+func (r *roleRepo) RevokeRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	defer observe(r.metrics, "role", "RevokeRole", time.Now())
+	return r.next.RevokeRole(ctx, req)
+}
+
+type productRepo struct {
+	next    storage.ProductRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (string, error) {
+	defer observe(r.metrics, "product", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey) (*models.Product, error) {
+	defer observe(r.metrics, "product", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (*models.GetListProductResponse, error) {
+	defer observe(r.metrics, "product", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (int64, error) {
+	defer observe(r.metrics, "product", "Update", time.Now())
+	return r.next.Update(ctx, req)
+}
+
+func (r *productRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+	defer observe(r.metrics, "product", "Patch", time.Now())
+	return r.next.Patch(ctx, req)
+}
+
+func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "product", "Delete", time.Now())
+	return r.next.Delete(ctx, req)
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "product", "Restore", time.Now())
+	return r.next.Restore(ctx, req)
+}
+
+func (r *productRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	defer observe(r.metrics, "product", "Purge", time.Now())
+	return r.next.Purge(ctx, req)
+}
+
+type categoryRepo struct {
+	next    storage.CategoryRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (string, error) {
+	defer observe(r.metrics, "category", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryKey) (*models.Category, error) {
+	defer observe(r.metrics, "category", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error) {
+	defer observe(r.metrics, "category", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "category", "Delete", time.Now())
+	return r.next.Delete(ctx, req)
+}
+
+func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
+	defer observe(r.metrics, "category", "Update", time.Now())
+	return r.next.Update(ctx, req)
+}
+
+func (r *categoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	defer observe(r.metrics, "category", "Patch", time.Now())
+	return r.next.Patch(ctx, req)
+}
+
+func (r *categoryRepo) GetTree(ctx context.Context) (*models.GetCategoryTreeResponse, error) {
+	defer observe(r.metrics, "category", "GetTree", time.Now())
+	return r.next.GetTree(ctx)
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "category", "Restore", time.Now())
+	return r.next.Restore(ctx, req)
+}
+
+func (r *categoryRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	defer observe(r.metrics, "category", "Purge", time.Now())
+	return r.next.Purge(ctx, req)
+}
+
+type clientRepo struct {
+	next    storage.ClientRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *clientRepo) Create(ctx context.Context, req *models.CreateClient) (string, error) {
+	defer observe(r.metrics, "client", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *clientRepo) GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error) {
+	defer observe(r.metrics, "client", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientRequest) (*models.GetListClientResponse, error) {
+	defer observe(r.metrics, "client", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int64, error) {
+	defer observe(r.metrics, "client", "Update", time.Now())
+	return r.next.Update(ctx, req)
+}
+
+func (r *clientRepo) Patch(ctx context.Context, req *models.PatchClient) (int64, error) {
+	defer observe(r.metrics, "client", "Patch", time.Now())
+	return r.next.Patch(ctx, req)
+}
+
+func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "client", "Delete", time.Now())
+	return r.next.Delete(ctx, req)
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "client", "Restore", time.Now())
+	return r.next.Restore(ctx, req)
+}
+
+func (r *clientRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	defer observe(r.metrics, "client", "Purge", time.Now())
+	return r.next.Purge(ctx, req)
+}
+
+type orderRepo struct {
+	next    storage.OrderRepoI
+	metrics *metrics.Metrics
+}
+
+func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
+	defer observe(r.metrics, "order", "Create", time.Now())
+	return r.next.Create(ctx, req)
+}
+
+func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
+	defer observe(r.metrics, "order", "GetByID", time.Now())
+	return r.next.GetByID(ctx, req)
+}
+
+func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest) (*models.GetListOrderResponse, error) {
+	defer observe(r.metrics, "order", "GetList", time.Now())
+	return r.next.GetList(ctx, req)
+}
+
+func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64, error) {
+	defer observe(r.metrics, "order", "Update", time.Now())
+	return r.next.Update(ctx, req)
+}
+
+func (r *orderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	defer observe(r.metrics, "order", "Patch", time.Now())
+	return r.next.Patch(ctx, req)
+}
+
+func (r *orderRepo) UpdateStatus(ctx context.Context, req *models.UpdateOrderStatus) (int64, error) {
+	defer observe(r.metrics, "order", "UpdateStatus", time.Now())
+	return r.next.UpdateStatus(ctx, req)
+}
+
+func (r *orderRepo) GetHistory(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetListOrderHistoryResponse, error) {
+	defer observe(r.metrics, "order", "GetHistory", time.Now())
+	return r.next.GetHistory(ctx, req)
+}
+
+func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "order", "Delete", time.Now())
+	return r.next.Delete(ctx, req)
+}
+
+func (r *orderRepo) AddOrderProduct(ctx context.Context, req *models.CreateOrderItem) (string, error) {
+	defer observe(r.metrics, "order", "AddOrderProduct", time.Now())
+	return r.next.AddOrderProduct(ctx, req)
+}
+
+func (r *orderRepo) RemoveOrderItem(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "order", "RemoveOrderItem", time.Now())
+	return r.next.RemoveOrderItem(ctx, req)
+}
+
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+	defer observe(r.metrics, "order", "Restore", time.Now())
+	return r.next.Restore(ctx, req)
+}
+
+func (r *orderRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	defer observe(r.metrics, "order", "Purge", time.Now())
+	return r.next.Purge(ctx, req)
+}
@@ -3,18 +3,19 @@ package postgresql
 import (
 	"app/api/models"
 	"app/pkg/helper"
+	"app/storage"
 	"context"
 	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/pgx/v4"
 )
 
 type categoryRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewCategoryRepo(db *pgxpool.Pool) *categoryRepo {
+func NewCategoryRepo(db DB) *categoryRepo {
 	return &categoryRepo{
 		db: db,
 	}
@@ -30,15 +31,27 @@ func (r *categoryRepo) Create(ctx context.Context, req *models.CreateCategory) (
 	query = `
 		INSERT INTO category(
 			id, 
+			parent_id,
 			name,
 			updated_at 
 		)
-		VALUES ( $1, $2, now())
+		VALUES ( $1, $2, $3, now())
 	`
-	_, err := r.db.Exec(ctx, query,
-		id,
-		req.Name,
-	)
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		err := checkCategoryParent(ctx, tx, id, req.ParentId)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, query,
+			id,
+			helper.NewNullString(req.ParentId),
+			req.Name,
+		)
+
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -56,18 +69,24 @@ func (r *categoryRepo) GetByID(ctx context.Context, req *models.CategoryPrimaryK
 	query = `
 		SELECT
 			id,
+			COALESCE(CAST(parent_id AS VARCHAR), ''),
 			name, 
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			version,
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM category
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`
 
-	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+	err := r.db.QueryRow(ctx, query, req.Id, req.IncludeDeleted).Scan(
 		&category.Id,
+		&category.ParentId,
 		&category.Name,
 		&category.CreatedAt,
 		&category.UpdatedAt,
+		&category.Version,
+		&category.DeletedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -82,7 +101,7 @@ func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 
 	var (
 		query  string
-		filter = " WHERE TRUE "
+		filter = helper.NewQueryBuilder()
 		offset = " OFFSET 0"
 		limit  = " LIMIT 10"
 	)
@@ -91,16 +110,21 @@ func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 		SELECT
 			COUNT(*) OVER(),
 			id,
+			COALESCE(CAST(parent_id AS VARCHAR), ''),
 			name, 
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			version,
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM category
 	`
 
-	if len(req.Search) > 0 {
-		filter += " AND name ILIKE '%' || '" + req.Search + "' || '%' "
+	if !req.IncludeDeleted {
+		filter.Where("deleted_at IS NULL")
 	}
 
+	filter.Search(req.Search, "name")
+
 	if req.Offset > 0 {
 		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
 	}
@@ -109,9 +133,9 @@ func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	query += filter + offset + limit
+	query += filter.WhereClause() + offset + limit
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -122,9 +146,12 @@ func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 		err = rows.Scan(
 			&resp.Count,
 			&category.Id,
+			&category.ParentId,
 			&category.Name,
 			&category.CreatedAt,
 			&category.UpdatedAt,
+			&category.Version,
+			&category.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -136,45 +163,258 @@ func (r *categoryRepo) GetList(ctx context.Context, req *models.GetListCategoryR
 	return resp, nil
 }
 
+// Update renames the category and moves it under ParentId, an empty
+// ParentId makes it a root. Moves are serialized so that two concurrent
+// moves can not close a cycle between them.
 func (r *categoryRepo) Update(ctx context.Context, req *models.UpdateCategory) (int64, error) {
 	var (
 		query  string
 		params map[string]interface{}
+		rows   int64
 	)
 
 	query = `
 		UPDATE
 		category
 		SET
-			id = :id,
+			version = version + 1,
+			parent_id = :parent_id,
 			name = :name,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
 	`
 
 	params = map[string]interface{}{
-		"id":   req.Id,
-		"name": req.Name,
+		"id":        req.Id,
+		"version":   req.Version,
+		"parent_id": helper.NewNullString(req.ParentId),
+		"name":      req.Name,
 	}
 
 	query, args := helper.ReplaceQueryParams(query, params)
 
-	result, err := r.db.Exec(ctx, query, args...)
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		err := checkCategoryParent(ctx, tx, req.Id, req.ParentId)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		rows = result.RowsAffected()
+
+		return checkVersion(ctx, tx, "category", req.Id, req.Version, rows)
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	return result.RowsAffected(), nil
+	return rows, nil
+}
+
+func (r *categoryRepo) Patch(ctx context.Context, req *models.PatchCategory) (int64, error) {
+	var rows int64
+
+	columns := map[string]interface{}{}
+
+	if req.ParentId != nil {
+		columns["parent_id"] = helper.NewNullString(*req.ParentId)
+	}
+	if req.Name != nil {
+		columns["name"] = *req.Name
+	}
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		if req.ParentId != nil {
+			err := checkCategoryParent(ctx, tx, req.Id, *req.ParentId)
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		rows, err = patchRow(ctx, tx, "category", req.Id, req.Version, columns)
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rows, nil
+}
+
+// checkCategoryParent fails with storage.ErrCategoryCycle when parentId is
+// the category itself or one of its descendants, and with a foreign key
+// error when it is not a live category, the subtree would vanish from the
+// tree under a deleted one. The tree lock it takes is held until the end
+// of tx, so concurrent moves cannot form a cycle together, and the parent
+// row stays locked against deletion until then.
+func checkCategoryParent(ctx context.Context, tx pgx.Tx, id, parentId string) error {
+	if len(parentId) <= 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('category_tree'))")
+	if err != nil {
+		return err
+	}
+
+	var cycle bool
+	err = tx.QueryRow(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM category WHERE id = $2
+			UNION
+			SELECT c.id, c.parent_id FROM category AS c JOIN ancestors AS a ON c.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $1)
+	`, id, parentId).Scan(&cycle)
+	if err != nil {
+		return err
+	}
+
+	if cycle {
+		return storage.ErrCategoryCycle
+	}
+
+	var live bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM category WHERE id = $1 AND deleted_at IS NULL FOR SHARE)
+	`, parentId).Scan(&live)
+	if err != nil {
+		return err
+	}
+
+	if !live {
+		return &storage.Error{Err: storage.ErrForeignKey, Field: "parent_id", Constraint: "category_parent_id_fkey"}
+	}
+
+	return nil
 }
 
 func (r *categoryRepo) Delete(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	query := `
+		UPDATE
+		category
+		SET
+			version = version + 1,
+			deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id, req.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkVersion(ctx, r.db, "category", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// GetTree returns the root categories with their descendants nested in
+// Children, siblings ordered by name. Deleted categories are left out
+// together with their subtrees.
+func (r *categoryRepo) GetTree(ctx context.Context) (*models.GetCategoryTreeResponse, error) {
+
+	var (
+		resp  = &models.GetCategoryTreeResponse{Categories: []*models.Category{}}
+		nodes = map[string]*models.Category{}
+	)
+
+	query := `
+		WITH RECURSIVE tree AS (
+			SELECT id, parent_id, name, created_at, updated_at, 0 AS depth
+			FROM category
+			WHERE parent_id IS NULL AND deleted_at IS NULL
+			UNION ALL
+			SELECT c.id, c.parent_id, c.name, c.created_at, c.updated_at, t.depth + 1
+			FROM category AS c
+			JOIN tree AS t ON c.parent_id = t.id
+			WHERE c.deleted_at IS NULL
+		)
+		SELECT
+			id,
+			COALESCE(CAST(parent_id AS VARCHAR), ''),
+			name,
+			CAST(created_at::timestamp AS VARCHAR),
+			COALESCE(CAST(updated_at::timestamp AS VARCHAR), '')
+		FROM tree
+		ORDER BY depth, name
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category models.Category
+		err = rows.Scan(
+			&category.Id,
+			&category.ParentId,
+			&category.Name,
+			&category.CreatedAt,
+			&category.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		nodes[category.Id] = &category
+
+		// rows come level by level, so the parent is always known already
+		if parent, ok := nodes[category.ParentId]; ok {
+			parent.Children = append(parent.Children, &category)
+		} else {
+			resp.Categories = append(resp.Categories, &category)
+		}
+	}
+
+	return resp, rows.Err()
+}
+
+func (r *categoryRepo) Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error) {
+	query := `
+		UPDATE
+		category
+		SET
+			version = version + 1,
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Purge removes the categories deleted more than req.Days days ago that no product
+// or subcategory refers to any more, so a deleted subtree goes one level per
+// run.
+func (r *categoryRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
 	query := `
 		DELETE
 		FROM category
-		WHERE id = $1
+		WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+			AND NOT EXISTS (SELECT 1 FROM product WHERE category_id = category.id)
+			AND NOT EXISTS (SELECT 1 FROM category AS child WHERE child.parent_id = category.id)
 	`
 
-	result, err := r.db.Exec(ctx, query, req.Id)
+	result, err := r.db.Exec(ctx, query, req.Days)
 	if err != nil {
 		return 0, err
 	}
@@ -7,14 +7,13 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 type clientRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewClientRepo(db *pgxpool.Pool) *clientRepo {
+func NewClientRepo(db DB) *clientRepo {
 	return &clientRepo{
 		db: db,
 	}
@@ -64,18 +63,22 @@ func (r *clientRepo) GetByID(ctx context.Context, req *models.ClientPrimaryKey)
 			last_name,
 			phone_number,
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			version,
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM client
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`
 
-	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+	err := r.db.QueryRow(ctx, query, req.Id, req.IncludeDeleted).Scan(
 		&client.Id,
 		&client.FirstName,
 		&client.LastName,
 		&client.PhoneNumber,
 		&client.CreatedAt,
 		&client.UpdatedAt,
+		&client.Version,
+		&client.DeletedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -89,28 +92,42 @@ func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientReque
 	resp = &models.GetListClientResponse{}
 
 	var (
-		query  string
-		filter = " WHERE TRUE "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
+		query       string
+		countColumn = "COUNT(*) OVER()"
+		filter      = helper.NewQueryBuilder()
+		offset      = " OFFSET 0"
+		limit       = " LIMIT 10"
 	)
 
+	// keyset pages skip the window count, which is what makes deep offset
+	// pages slow
+	if req.Keyset {
+		countColumn = "0"
+	}
+
+	if !req.IncludeDeleted {
+		filter.Where("deleted_at IS NULL")
+	}
+
+	rankColumn, highlightColumn := fullTextSearch(filter, req.Search, "search_vector",
+		"first_name || ' ' || last_name || ' ' || phone_number")
+
 	query = `
 		SELECT
-			COUNT(*) OVER(),
+			` + countColumn + `,
+			` + rankColumn + ` AS search_rank,
+			` + highlightColumn + `,
 			id, 
 			first_name,
 			last_name,
 			phone_number,
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			version,
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM client
 	`
 
-	if len(req.Search) > 0 {
-		filter += " AND name ILIKE '%' || '" + req.Search + "' || '%' "
-	}
-
 	if req.Offset > 0 {
 		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
 	}
@@ -119,9 +136,23 @@ func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientReque
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	query += filter + offset + limit
+	tail := offset + limit
+
+	// the best matches come first
+	if len(req.Search) > 0 {
+		tail = " ORDER BY search_rank DESC, id" + tail
+	}
+
+	if req.Keyset {
+		tail, err = keysetPage(filter, "", req.Cursor, req.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	rows, err := r.db.Query(ctx, query)
+	query += filter.WhereClause() + tail
+
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -131,12 +162,16 @@ func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientReque
 		var client models.Client
 		err = rows.Scan(
 			&resp.Count,
+			&client.Rank,
+			&client.Highlight,
 			&client.Id,
 			&client.FirstName,
 			&client.LastName,
 			&client.PhoneNumber,
 			&client.CreatedAt,
 			&client.UpdatedAt,
+			&client.Version,
+			&client.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -145,6 +180,13 @@ func (r *clientRepo) GetList(ctx context.Context, req *models.GetListClientReque
 		resp.Clients = append(resp.Clients, &client)
 	}
 
+	if req.Keyset && len(resp.Clients) > keysetLimit(req.Limit) {
+		resp.Clients = resp.Clients[:keysetLimit(req.Limit)]
+
+		last := resp.Clients[len(resp.Clients)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
 	return resp, nil
 }
 
@@ -158,16 +200,17 @@ func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int6
 		UPDATE
 		client
 		SET
-			id = :id,
+			version = version + 1,
 			first_name = :first_name,
 			last_name = :last_name,
 			phone_number = :phone_number,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
 	`
 
 	params = map[string]interface{}{
 		"id":           req.Id,
+		"version":      req.Version,
 		"first_name":   req.FirstName,
 		"last_name":    req.LastName,
 		"phone_number": req.PhoneNumber,
@@ -180,14 +223,62 @@ func (r *clientRepo) Update(ctx context.Context, req *models.UpdateClient) (int6
 		return 0, err
 	}
 
+	err = checkVersion(ctx, r.db, "client", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
 	return result.RowsAffected(), nil
 }
 
+func (r *clientRepo) Patch(ctx context.Context, req *models.PatchClient) (int64, error) {
+	columns := map[string]interface{}{}
+
+	if req.FirstName != nil {
+		columns["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		columns["last_name"] = *req.LastName
+	}
+	if req.PhoneNumber != nil {
+		columns["phone_number"] = *req.PhoneNumber
+	}
+
+	return patchRow(ctx, r.db, "client", req.Id, req.Version, columns)
+}
+
 func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
 	query := `
-		DELETE 
-		FROM client
-		WHERE id = $1
+		UPDATE
+		client
+		SET
+			version = version + 1,
+			deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id, req.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkVersion(ctx, r.db, "client", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *clientRepo) Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error) {
+	query := `
+		UPDATE
+		client
+		SET
+			version = version + 1,
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE id = $1 AND deleted_at IS NOT NULL
 	`
 
 	result, err := r.db.Exec(ctx, query, req.Id)
@@ -197,3 +288,21 @@ func (r *clientRepo) Delete(ctx context.Context, req *models.ClientPrimaryKey) (
 
 	return result.RowsAffected(), nil
 }
+
+// Purge removes the clients deleted more than req.Days days ago that no order
+// refers to any more.
+func (r *clientRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	query := `
+		DELETE
+		FROM client
+		WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+			AND NOT EXISTS (SELECT 1 FROM orders WHERE client_id = client.id)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Days)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
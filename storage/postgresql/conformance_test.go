@@ -0,0 +1,13 @@
+package postgresql
+
+import (
+	"app/storage"
+	"app/storage/storagetest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.StorageI {
+		return testStore
+	})
+}
@@ -0,0 +1,99 @@
+package postgresql
+
+import (
+	"app/storage"
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// keyDetail extracts the column from details like
+// "Key (login)=(admin) already exists."
+var keyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// translateError turns pgx errors into the errors of the storage package,
+// other errors are returned as they are.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	field := pgErr.ColumnName
+	if match := keyDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+		field = match[1]
+	}
+
+	var sentinel error
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		sentinel = storage.ErrConflict
+	case "23503": // foreign_key_violation
+		sentinel = storage.ErrForeignKey
+	case "23514", "23502": // check_violation, not_null_violation
+		sentinel = storage.ErrCheckViolation
+	default:
+		return err
+	}
+
+	return &storage.Error{
+		Err:        sentinel,
+		Field:      field,
+		Constraint: pgErr.ConstraintName,
+	}
+}
+
+// translatingDB translates the errors of the queries run through it, so
+// that repositories return storage errors without handling them one by
+// one. Transactions begun through it are translated by execTx.
+type translatingDB struct {
+	DB
+}
+
+func (db translatingDB) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := db.DB.Exec(ctx, sql, arguments...)
+	return tag, translateError(err)
+}
+
+func (db translatingDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := db.DB.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return translatingRows{rows}, nil
+}
+
+func (db translatingDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return translatingRow{db.DB.QueryRow(ctx, sql, args...)}
+}
+
+type translatingRow struct {
+	pgx.Row
+}
+
+func (r translatingRow) Scan(dest ...interface{}) error {
+	return translateError(r.Row.Scan(dest...))
+}
+
+type translatingRows struct {
+	pgx.Rows
+}
+
+func (r translatingRows) Scan(dest ...interface{}) error {
+	return translateError(r.Rows.Scan(dest...))
+}
+
+func (r translatingRows) Err() error {
+	return translateError(r.Rows.Err())
+}
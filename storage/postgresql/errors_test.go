@@ -0,0 +1,80 @@
+package postgresql
+
+import (
+	"app/storage"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  error
+		Output error
+		Field  string
+	}{
+		{
+			Name:   "no rows",
+			Input:  fmt.Errorf("scan: %w", pgx.ErrNoRows),
+			Output: storage.ErrNotFound,
+		},
+		{
+			Name: "unique",
+			Input: &pgconn.PgError{
+				Code:           "23505",
+				ConstraintName: "users_login_key",
+				Detail:         "Key (login)=(admin) already exists.",
+			},
+			Output: storage.ErrConflict,
+			Field:  "login",
+		},
+		{
+			Name: "foreign key",
+			Input: &pgconn.PgError{
+				Code:   "23503",
+				Detail: `Key (client_id)=(8b5f3c1e-0c4e-4b8e-9d5a-1f0e6a7b2c3d) is not present in table "client".`,
+			},
+			Output: storage.ErrForeignKey,
+			Field:  "client_id",
+		},
+		{
+			Name:   "not null",
+			Input:  &pgconn.PgError{Code: "23502", ColumnName: "first_name"},
+			Output: storage.ErrCheckViolation,
+			Field:  "first_name",
+		},
+		{
+			Name:   "other",
+			Input:  &pgconn.PgError{Code: "42P01"},
+			Output: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+
+			err := translateError(test.Input)
+
+			if test.Output == nil {
+				if err != test.Input {
+					t.Errorf("%s: got: %v, expected the error unchanged", test.Name, err)
+				}
+				return
+			}
+
+			if !errors.Is(err, test.Output) {
+				t.Errorf("%s: got: %v, expected: %v", test.Name, err, test.Output)
+				return
+			}
+
+			var storageErr *storage.Error
+			if len(test.Field) > 0 && (!errors.As(err, &storageErr) || storageErr.Field != test.Field) {
+				t.Errorf("%s: got: %v, expected field: %s", test.Name, err, test.Field)
+			}
+		})
+	}
+}
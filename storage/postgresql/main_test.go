@@ -15,6 +15,9 @@ var (
 	productTestRepo  *productRepo
 	clientTestRepo   *clientRepo
 	orderTestRepo    *orderRepo
+
+	// testStore runs the storagetest conformance suite
+	testStore *Store
 )
 
 func TestMain(m *testing.M) {
@@ -43,6 +46,7 @@ func TestMain(m *testing.M) {
 	productTestRepo = NewProductRepo(pool)
 	clientTestRepo = NewClientRepo(pool)
 	orderTestRepo = NewOrderRepo(pool)
+	testStore = newStore(pool)
 
 	os.Exit(m.Run())
 }
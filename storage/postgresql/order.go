@@ -3,23 +3,65 @@ package postgresql
 import (
 	"app/api/models"
 	"app/pkg/helper"
+	"app/storage"
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jackc/pgx/v4"
 )
 
 type orderRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewOrderRepo(db *pgxpool.Pool) *orderRepo {
+func NewOrderRepo(db DB) *orderRepo {
 	return &orderRepo{
 		db: db,
 	}
 }
 
+// orderProductsQuery aggregates the lines of order "o" together with their
+// product and category, so an order and its lines are read in one query.
+// It is a select list subquery rather than a join, so list queries only
+// build lines for the rows of the requested page.
+const orderProductsQuery = `
+	COALESCE((
+		SELECT
+			JSONB_AGG(
+				JSONB_BUILD_OBJECT(
+					'id', oi.id,
+					'order_id', oi.order_id,
+					'product_id', oi.product_id,
+					'quantity', oi.quantity,
+					'unit_price', oi.unit_price,
+					'total_price', oi.quantity * oi.unit_price,
+					'created_at', CAST(oi.created_at::timestamp AS VARCHAR),
+					'product_data', JSONB_BUILD_OBJECT(
+						'id', p.id,
+						'name', COALESCE(p.name, ''),
+						'category_id', p.category_id,
+						'category_data', JSONB_BUILD_OBJECT(
+							'id', pc.id,
+							'name', pc.name,
+							'created_at', CAST(pc.created_at::timestamp AS VARCHAR),
+							'updated_at', CAST(pc.updated_at::timestamp AS VARCHAR)
+						),
+						'description', COALESCE(p.description, ''),
+						'price', p.price,
+						'quantity', COALESCE(p.quantity, 0),
+						'created_at', CAST(p.created_at::timestamp AS VARCHAR),
+						'updated_at', CAST(p.updated_at::timestamp AS VARCHAR)
+					)
+				) ORDER BY oi.created_at, oi.id
+			)
+		FROM order_products AS oi
+		JOIN product AS p ON p.id = oi.product_id
+		JOIN category AS pc ON pc.id = p.category_id
+		WHERE oi.order_id = o.id
+	), '[]')`
+
 func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (string, error) {
 	var (
 		query string
@@ -36,16 +78,27 @@ func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (string
 			status,
 			updated_at
 		)
-		VALUES ($1, $2, $3, $4, now())
+		VALUES ($1, $2, 0, $3, now())
 	`
 
-	_, err := r.db.Exec(ctx, query,
-		id,
-		req.ClientId,
-		helper.NewNullInt32(int(req.Price)),
-		helper.NewNullString(req.Status),
-	)
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		_, err := tx.Exec(ctx, query,
+			id,
+			req.ClientId,
+			models.OrderStatusNew,
+		)
+		if err != nil {
+			return err
+		}
 
+		return addOrderHistory(ctx, tx, &models.CreateOrderHistory{
+			OrderId:   id,
+			ToStatus:  models.OrderStatusNew,
+			ChangedBy: req.CreatedBy,
+			Reason:    "order created",
+		})
+	})
 	if err != nil {
 		return "", err
 	}
@@ -55,8 +108,9 @@ func (r *orderRepo) Create(ctx context.Context, req *models.CreateOrder) (string
 
 func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error) {
 	var (
-		query string
-		order models.Order
+		query         string
+		order         models.Order
+		orderProducts []byte
 	)
 
 	query = `
@@ -74,15 +128,18 @@ func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*
 			COALESCE(o.price, 0),
 			COALESCE(o.status, ''),
 			CAST(o.created_at::timestamp AS VARCHAR),
-			CAST(o.updated_at::timestamp AS VARCHAR)
+			CAST(o.updated_at::timestamp AS VARCHAR),
+			o.version,
+			COALESCE(CAST(o.deleted_at::timestamp AS VARCHAR), ''),
+	` + orderProductsQuery + `
 		FROM "orders" AS o
 		JOIN client AS c ON c.id = o.client_id
-		WHERE o.id = $1
+		WHERE o.id = $1 AND (o.deleted_at IS NULL OR $2)
 	`
 
 	order.ClientData = &models.Client{}
 
-	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+	err := r.db.QueryRow(ctx, query, req.Id, req.IncludeDeleted).Scan(
 		&order.Id,
 		&order.ClientId,
 		&order.ClientData.Id,
@@ -96,11 +153,28 @@ func (r *orderRepo) GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*
 		&order.Status,
 		&order.CreatedAt,
 		&order.UpdatedAt,
+		&order.Version,
+		&order.DeletedAt,
+		&orderProducts,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	err = json.Unmarshal(orderProducts, &order.OrderProducts)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.WithHistory {
+		history, err := r.GetHistory(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		order.History = history.History
+	}
+
 	return &order, nil
 }
 
@@ -109,15 +183,27 @@ func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest
 	resp = &models.GetListOrderResponse{}
 
 	var (
-		query  string
-		filter = " WHERE TRUE "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
+		query          string
+		countColumn    = "COUNT(*) OVER()"
+		filter         = helper.NewQueryBuilder()
+		offset         = " OFFSET 0"
+		limit          = " LIMIT 10"
+		productsColumn = " NULL::jsonb "
 	)
 
+	if req.WithProducts {
+		productsColumn = orderProductsQuery
+	}
+
+	// keyset pages skip the window count, which is what makes deep offset
+	// pages slow
+	if req.Keyset {
+		countColumn = "0"
+	}
+
 	query = `
 	SELECT
-		COUNT(*) OVER(),
+		` + countColumn + `,
 		o.id, 
 		o.client_id, 
 
@@ -131,15 +217,20 @@ func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest
 		COALESCE(o.price, 0),
 		COALESCE(o.status, ''),
 		CAST(o.created_at::timestamp AS VARCHAR),
-		CAST(o.updated_at::timestamp AS VARCHAR)
+		CAST(o.updated_at::timestamp AS VARCHAR),
+		o.version,
+		COALESCE(CAST(o.deleted_at::timestamp AS VARCHAR), ''),
+	` + productsColumn + `
 	FROM "orders" AS o
 	JOIN client AS c ON c.id = o.client_id
 	`
 
-	if len(req.Search) > 0 {
-		filter += " AND name ILIKE '%' || '" + req.Search + "' || '%' "
+	if !req.IncludeDeleted {
+		filter.Where("o.deleted_at IS NULL")
 	}
 
+	filter.Search(req.Search, "c.first_name", "c.last_name", "c.phone_number")
+
 	if req.Offset > 0 {
 		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
 	}
@@ -148,16 +239,28 @@ func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	query += filter + offset + limit
+	tail := offset + limit
+
+	if req.Keyset {
+		tail, err = keysetPage(filter, "o.", req.Cursor, req.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query += filter.WhereClause() + tail
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var order models.Order
+		var (
+			order         models.Order
+			orderProducts []byte
+		)
 		order.ClientData = &models.Client{}
 
 		err = rows.Scan(
@@ -175,14 +278,31 @@ func (r *orderRepo) GetList(ctx context.Context, req *models.GetListOrderRequest
 			&order.Status,
 			&order.CreatedAt,
 			&order.UpdatedAt,
+			&order.Version,
+			&order.DeletedAt,
+			&orderProducts,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		if orderProducts != nil {
+			err = json.Unmarshal(orderProducts, &order.OrderProducts)
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		resp.Orders = append(resp.Orders, &order)
 	}
 
+	if req.Keyset && len(resp.Orders) > keysetLimit(req.Limit) {
+		resp.Orders = resp.Orders[:keysetLimit(req.Limit)]
+
+		last := resp.Orders[len(resp.Orders)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
 	return resp, nil
 }
 
@@ -196,19 +316,16 @@ func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64,
 		UPDATE
 		orders
 		SET
-			id = :id, 
+			version = version + 1,
 			client_id = :client_id, 
-			price = :price,
-			status = :status,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
 	`
 
 	params = map[string]interface{}{
 		"id":        req.Id,
+		"version":   req.Version,
 		"client_id": req.ClientId,
-		"price":     req.Price,
-		"status":    req.Status,
 	}
 
 	query, args := helper.ReplaceQueryParams(query, params)
@@ -218,43 +335,336 @@ func (r *orderRepo) Update(ctx context.Context, req *models.UpdateOrder) (int64,
 		return 0, err
 	}
 
+	err = checkVersion(ctx, r.db, "orders", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
 	return result.RowsAffected(), nil
 }
 
+func (r *orderRepo) Patch(ctx context.Context, req *models.PatchOrder) (int64, error) {
+	columns := map[string]interface{}{}
+
+	if req.ClientId != nil {
+		columns["client_id"] = *req.ClientId
+	}
+
+	return patchRow(ctx, r.db, "orders", req.Id, req.Version, columns)
+}
+
+// UpdateStatus moves the order to req.Status when the transition is allowed
+// and applies its side effects in the same transaction.
+func (r *orderRepo) UpdateStatus(ctx context.Context, req *models.UpdateOrderStatus) (int64, error) {
+
+	var rowsAffected int64
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		status, price, err := lockOrder(ctx, tx, req.Id)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !models.CanTransitionOrderStatus(status, req.Status) {
+			return storage.ErrInvalidTransition
+		}
+
+		result, err := tx.Exec(ctx, `
+			UPDATE
+			orders
+			SET
+				version = version + 1,
+				status = $2,
+				updated_at = now()
+			WHERE id = $1
+		`, req.Id, req.Status)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected = result.RowsAffected()
+
+		err = addOrderHistory(ctx, tx, &models.CreateOrderHistory{
+			OrderId:    req.Id,
+			FromStatus: status,
+			ToStatus:   req.Status,
+			FromPrice:  price,
+			ToPrice:    price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     req.Reason,
+		})
+		if err != nil {
+			return err
+		}
+
+		// goods of cancelled orders, and of orders refunded before they
+		// were shipped, go back to the stock
+		if req.Status == models.OrderStatusCancelled ||
+			(req.Status == models.OrderStatusRefunded && status == models.OrderStatusPaid) {
+			return releaseOrderStock(ctx, tx, req.Id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// Delete soft deletes the order, the goods it still holds go back to the
+// stock in the same transaction.
 func (r *orderRepo) Delete(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+
+	var rowsAffected int64
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		var status string
+		err := tx.QueryRow(ctx, `
+			UPDATE
+			orders
+			SET
+				version = version + 1,
+				deleted_at = now()
+			WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+			RETURNING COALESCE(status, '')
+		`, req.Id, req.Version).Scan(&status)
+		if err == pgx.ErrNoRows {
+			return checkVersion(ctx, tx, "orders", req.Id, req.Version, 0)
+		}
+		if err != nil {
+			return err
+		}
+
+		rowsAffected = 1
+
+		if models.OrderHoldsStock(status) {
+			return releaseOrderStock(ctx, tx, req.Id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// Restore brings the order back and reserves the goods Delete released
+// again, failing with ErrInsufficientStock when they are gone.
+func (r *orderRepo) Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error) {
+
+	var rowsAffected int64
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		var status string
+		err := tx.QueryRow(ctx, `
+			UPDATE
+			orders
+			SET
+				version = version + 1,
+				deleted_at = NULL,
+				updated_at = now()
+			WHERE id = $1 AND deleted_at IS NOT NULL
+			RETURNING COALESCE(status, '')
+		`, req.Id).Scan(&status)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rowsAffected = 1
+
+		if models.OrderHoldsStock(status) {
+			return reserveOrderStock(ctx, tx, req.Id)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// Purge removes the orders deleted more than req.Days days ago together
+// with their lines, the status history goes with them. Delete released
+// their goods already.
+func (r *orderRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+
+	var rowsAffected int64
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		_, err := tx.Exec(ctx, `
+			DELETE
+			FROM order_products
+			WHERE order_id IN (
+				SELECT id FROM orders WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+			)
+		`, req.Days)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(ctx, `
+			DELETE
+			FROM orders
+			WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+		`, req.Days)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected = result.RowsAffected()
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+func (r *orderRepo) GetHistory(ctx context.Context, req *models.OrderPrimaryKey) (resp *models.GetListOrderHistoryResponse, err error) {
+
+	resp = &models.GetListOrderHistoryResponse{}
+
 	query := `
-		DELETE 
-		FROM orders
-		WHERE id = $1
+		SELECT
+			id,
+			order_id,
+			COALESCE(from_status, ''),
+			to_status,
+			COALESCE(from_price, 0),
+			COALESCE(to_price, 0),
+			COALESCE(CAST(changed_by AS VARCHAR), ''),
+			COALESCE(reason, ''),
+			CAST(created_at::timestamp AS VARCHAR)
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY created_at, id
 	`
 
-	result, err := r.db.Exec(ctx, query, req.Id)
+	rows, err := r.db.Query(ctx, query, req.Id)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return result.RowsAffected(), nil
+	defer rows.Close()
+
+	for rows.Next() {
+		var history models.OrderHistory
+		err = rows.Scan(
+			&history.Id,
+			&history.OrderId,
+			&history.FromStatus,
+			&history.ToStatus,
+			&history.FromPrice,
+			&history.ToPrice,
+			&history.ChangedBy,
+			&history.Reason,
+			&history.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.History = append(resp.History, &history)
+	}
+
+	resp.Count = len(resp.History)
+
+	return resp, nil
 }
 
 // -------------ORDER_PRODUCTS-----------------------------------------------------------------------------------------------
 func (r *orderRepo) AddOrderProduct(ctx context.Context, req *models.CreateOrderItem) (string, error) {
 	id := uuid.NewString()
 
-	query := `
-		INSERT INTO order_products(
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		// lock the order so concurrent line changes recalculate its total one by one
+		status, price, err := lockOrder(ctx, tx, req.OrderId)
+		if err != nil {
+			return err
+		}
+
+		if status != models.OrderStatusNew {
+			return storage.ErrOrderFrozen
+		}
+
+		// the conditional update reserves the stock atomically, concurrent
+		// reservations of the same product wait for each other on its row
+		var unitPrice float64
+		err = tx.QueryRow(ctx, `
+			UPDATE
+			product
+			SET
+				quantity = quantity - $2,
+				updated_at = now()
+			WHERE id = $1 AND quantity >= $2 AND deleted_at IS NULL
+			RETURNING price
+		`, req.ProductId, req.Quantity).Scan(&unitPrice)
+		if err == pgx.ErrNoRows {
+			var exists bool
+			err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM product WHERE id = $1 AND deleted_at IS NULL)`, req.ProductId).Scan(&exists)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				return storage.ErrNotFound
+			}
+
+			return storage.ErrInsufficientStock
+		}
+		if err != nil {
+			return err
+		}
+
+		query := `
+			INSERT INTO order_products(
+				id,
+				order_id,
+				product_id,
+				quantity,
+				unit_price
+			)
+			VALUES ($1, $2, $3, $4, $5)
+		`
+
+		_, err = tx.Exec(ctx, query,
 			id,
-			order_id,
-			product_id
+			req.OrderId,
+			req.ProductId,
+			req.Quantity,
+			unitPrice,
 		)
-		VALUES (
-			$1, $2, $3)
-	`
-
-	_, err := r.db.Exec(ctx, query,
-		id,
-		req.OrderId,
-		req.ProductId,
-	)
+		if err != nil {
+			return err
+		}
 
+		return recalculateOrderPrice(ctx, tx, &models.CreateOrderHistory{
+			OrderId:    req.OrderId,
+			FromStatus: status,
+			ToStatus:   status,
+			FromPrice:  price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     "order line added",
+		})
+	})
 	if err != nil {
 		return "", err
 	}
@@ -264,14 +674,215 @@ func (r *orderRepo) AddOrderProduct(ctx context.Context, req *models.CreateOrder
 
 func (r *orderRepo) RemoveOrderItem(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error) {
 
+	var rowsAffected int64
+
+	err := execTx(ctx, r.db, func(tx pgx.Tx) error {
+
+		var (
+			orderId string
+			status  string
+			price   float64
+		)
+		err := tx.QueryRow(ctx, `
+			SELECT
+				o.id,
+				COALESCE(o.status, ''),
+				COALESCE(o.price, 0)
+			FROM order_products AS op
+			JOIN orders AS o ON o.id = op.order_id
+			WHERE op.id = $1 AND o.deleted_at IS NULL
+			FOR UPDATE OF o
+		`, req.Id).Scan(&orderId, &status, &price)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if status != models.OrderStatusNew {
+			return storage.ErrOrderFrozen
+		}
+
+		var (
+			productId string
+			quantity  int
+		)
+		err = tx.QueryRow(ctx, `
+			DELETE FROM order_products WHERE id = $1
+			RETURNING product_id, quantity
+		`, req.Id).Scan(&productId, &quantity)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected = 1
+
+		_, err = tx.Exec(ctx, `
+			UPDATE
+			product
+			SET
+				quantity = COALESCE(quantity, 0) + $2,
+				updated_at = now()
+			WHERE id = $1
+		`, productId, quantity)
+		if err != nil {
+			return err
+		}
+
+		return recalculateOrderPrice(ctx, tx, &models.CreateOrderHistory{
+			OrderId:    orderId,
+			FromStatus: status,
+			ToStatus:   status,
+			FromPrice:  price,
+			ChangedBy:  req.ChangedBy,
+			Reason:     "order line removed",
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// lockOrder locks the order row until the end of tx and returns its
+// status and price
+func lockOrder(ctx context.Context, tx pgx.Tx, orderId string) (string, float64, error) {
+	var (
+		status string
+		price  float64
+	)
+
+	err := tx.QueryRow(ctx, `
+		SELECT
+			COALESCE(status, ''),
+			COALESCE(price, 0)
+		FROM orders
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, orderId).Scan(&status, &price)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return status, price, nil
+}
+
+// releaseOrderStock returns the quantity of every line of the order to
+// the product stock
+func releaseOrderStock(ctx context.Context, tx pgx.Tx, orderId string) error {
 	query := `
-		DELETE FROM order_products WHERE id = $1
+		UPDATE
+		product AS p
+		SET
+			quantity = COALESCE(p.quantity, 0) + l.quantity,
+			updated_at = now()
+		FROM (
+			SELECT product_id, SUM(quantity) AS quantity
+			FROM order_products
+			WHERE order_id = $1
+			GROUP BY product_id
+		) AS l
+		WHERE p.id = l.product_id
+	`
+
+	_, err := tx.Exec(ctx, query, orderId)
+
+	return err
+}
+
+// reserveOrderStock takes the quantity of every line of the order from the
+// product stock, all or nothing
+func reserveOrderStock(ctx context.Context, tx pgx.Tx, orderId string) error {
+	query := `
+		UPDATE
+		product AS p
+		SET
+			quantity = p.quantity - l.quantity,
+			updated_at = now()
+		FROM (
+			SELECT product_id, SUM(quantity) AS quantity
+			FROM order_products
+			WHERE order_id = $1
+			GROUP BY product_id
+		) AS l
+		WHERE p.id = l.product_id AND p.quantity >= l.quantity
 	`
-	res, err := r.db.Exec(ctx, query, req.Id)
 
+	result, err := tx.Exec(ctx, query, orderId)
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	var products int64
+	err = tx.QueryRow(ctx, `SELECT COUNT(DISTINCT product_id) FROM order_products WHERE order_id = $1`, orderId).Scan(&products)
+	if err != nil {
+		return err
 	}
 
-	return res.RowsAffected(), nil
+	// the products that were short were left out of the update
+	if result.RowsAffected() < products {
+		return storage.ErrInsufficientStock
+	}
+
+	return nil
+}
+
+// recalculateOrderPrice sets the order total to the sum of its lines and
+// records the change in the order history
+func recalculateOrderPrice(ctx context.Context, tx pgx.Tx, history *models.CreateOrderHistory) error {
+	query := `
+		UPDATE
+		orders
+		SET
+			price = (
+				SELECT COALESCE(SUM(quantity * unit_price), 0)
+				FROM order_products
+				WHERE order_id = $1
+			),
+			updated_at = now()
+		WHERE id = $1
+		RETURNING price
+	`
+
+	err := tx.QueryRow(ctx, query, history.OrderId).Scan(&history.ToPrice)
+	if err != nil {
+		return err
+	}
+
+	if history.FromPrice == history.ToPrice {
+		return nil
+	}
+
+	return addOrderHistory(ctx, tx, history)
+}
+
+func addOrderHistory(ctx context.Context, tx pgx.Tx, req *models.CreateOrderHistory) error {
+	query := `
+		INSERT INTO order_status_history(
+			id,
+			order_id,
+			from_status,
+			to_status,
+			from_price,
+			to_price,
+			changed_by,
+			reason
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := tx.Exec(ctx, query,
+		uuid.NewString(),
+		req.OrderId,
+		helper.NewNullString(req.FromStatus),
+		req.ToStatus,
+		req.FromPrice,
+		req.ToPrice,
+		helper.NewNullString(req.ChangedBy),
+		helper.NewNullString(req.Reason),
+	)
+
+	return err
 }
@@ -3,7 +3,6 @@ package postgresql
 import (
 	"app/api/models"
 	"context"
-	"math/rand"
 	"testing"
 )
 
@@ -18,8 +17,6 @@ func TestCreateOrder(t *testing.T) {
 			Name: "Case 1",
 			Input: &models.CreateOrder{
 				ClientId: "eeb13e6e-2312-43e6-a926-dc7b0ac6ff45",
-				Price:    float64(rand.Intn(1000000-100) + 100),
-				Status:   "new",
 			},
 			WantErr: false,
 		},
@@ -94,8 +91,6 @@ func TestUpdateOrder(t *testing.T) {
 			Input: &models.UpdateOrder{
 				Id:       "83d30858-c9e2-49cc-8fa5-23e49a72a793",
 				ClientId: "eeb13e6e-2312-43e6-a926-dc7b0ac6ff45",
-				Price:    200000,
-				Status:   "in_proccess",
 			},
 			Output:  1,
 			WantErr: false,
@@ -169,6 +164,7 @@ func TestCreateOrderProduct(t *testing.T) {
 			Input: &models.CreateOrderItem{
 				OrderId:   "05102f47-8dbe-4c80-b8db-0a00d0ad2c28",
 				ProductId: "62d5cb0b-9798-4eeb-8fb0-156734306e68",
+				Quantity:  3,
 			},
 			WantErr: false,
 		},
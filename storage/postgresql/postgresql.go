@@ -1,24 +1,63 @@
 package postgresql
 
 import (
+	"app/api/models"
 	"app/config"
+	"app/pkg/helper"
+	"app/pkg/migrate"
 	"app/storage"
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// DB is implemented by both *pgxpool.Pool and pgx.Tx, so repositories run
+// the same statements inside and outside of a transaction. Begin on a
+// pgx.Tx starts a savepoint.
+type DB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 type Store struct {
-	db       *pgxpool.Pool
+	pool     *pgxpool.Pool
+	db       DB
 	product  storage.ProductRepoI
 	category storage.CategoryRepoI
 	client   storage.ClientRepoI
 	order    storage.OrderRepoI
 	user     storage.UserRepoI
+	session  storage.SessionRepoI
+	role     storage.RoleRepoI
 }
 
 func NewConnectPostgresql(cfg *config.Config) (storage.StorageI, error) {
+	pgpool, err := NewPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(pgpool), nil
+}
+
+// NewStore is the storage on top of pool, CloseDB closes the pool.
+func NewStore(pool *pgxpool.Pool) *Store {
+	store := newStore(pool)
+	store.pool = pool
+
+	return store
+}
+
+// NewPool connects to the database of cfg.
+func NewPool(cfg *config.Config) (*pgxpool.Pool, error) {
 	config, err := pgxpool.ParseConfig(fmt.Sprintf(
 		"host=%s user=%s dbname=%s password=%s port=%s sslmode=disable",
 		cfg.PostgresHost,
@@ -31,61 +70,267 @@ func NewConnectPostgresql(cfg *config.Config) (storage.StorageI, error) {
 		return nil, err
 	}
 
-	pgpool, err := pgxpool.ConnectConfig(context.Background(), config)
+	return pgxpool.ConnectConfig(context.Background(), config)
+}
+
+func newStore(db DB) *Store {
+	db = translatingDB{db}
+
+	return &Store{
+		db:       db,
+		product:  NewProductRepo(db),
+		category: NewCategoryRepo(db),
+		client:   NewClientRepo(db),
+		order:    NewOrderRepo(db),
+		user:     NewUserRepo(db),
+		session:  NewSessionRepo(db),
+		role:     NewRoleRepo(db),
+	}
+}
+
+// WithTx runs fn with a store whose repositories share one transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+// Calling WithTx on the store passed to fn opens a savepoint. The store
+// passed to fn must not be used concurrently or after fn returns.
+func (s *Store) WithTx(ctx context.Context, fn func(tx storage.StorageI) error) (err error) {
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return translateError(err)
 	}
 
-	return &Store{
-		db:       pgpool,
-		product:  NewProductRepo(pgpool),
-		category: NewCategoryRepo(pgpool),
-		client:   NewClientRepo(pgpool),
-		order:    NewOrderRepo(pgpool),
-		user:     NewUserRepo(pgpool),
-	}, nil
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+
+	err = fn(newStore(tx))
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+
+	return translateError(tx.Commit(ctx))
+}
+
+// execTx runs fn inside a transaction which is committed when fn succeeds
+// and rolled back otherwise. fn works on the raw transaction, its errors
+// are translated on the way out.
+func execTx(ctx context.Context, db DB, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = fn(tx)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return translateError(tx.Commit(ctx))
 }
 
+// CloseDB closes the connection pool, it does nothing for the store of a
+// transaction.
 func (s *Store) CloseDB() {
-	s.db.Close()
+	if s.pool != nil {
+		s.pool.Close()
+	}
 }
 
-func (s *Store) User() storage.UserRepoI {
-	if s.user == nil {
-		s.user = NewUserRepo(s.db)
+// Ping checks the database with a connection of the pool and reads the
+// version recorded by the migrations, 0 when they never ran.
+func (s *Store) Ping(ctx context.Context) (*models.DatabaseHealth, error) {
+	if s.pool != nil {
+		err := s.pool.Ping(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
+	var (
+		health  models.DatabaseHealth
+		applied bool
+	)
+
+	err := s.db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", migrate.Table).Scan(&applied)
+	if err != nil {
+		return nil, err
+	}
+
+	if applied {
+		err = s.db.QueryRow(ctx, "SELECT version, dirty FROM "+migrate.Table+" LIMIT 1").Scan(
+			&health.MigrationVersion,
+			&health.MigrationDirty,
+		)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, err
+		}
+
+		if health.MigrationVersion < 0 {
+			health.MigrationVersion = 0
+		}
+	}
+
+	if s.pool != nil {
+		stat := s.pool.Stat()
+		health.Pool = &models.PoolStats{
+			MaxConns:             stat.MaxConns(),
+			TotalConns:           stat.TotalConns(),
+			AcquiredConns:        stat.AcquiredConns(),
+			IdleConns:            stat.IdleConns(),
+			ConstructingConns:    stat.ConstructingConns(),
+			AcquireCount:         stat.AcquireCount(),
+			EmptyAcquireCount:    stat.EmptyAcquireCount(),
+			CanceledAcquireCount: stat.CanceledAcquireCount(),
+			AcquireDuration:      stat.AcquireDuration().String(),
+		}
+	}
+
+	return &health, nil
+}
+
+func (s *Store) User() storage.UserRepoI {
 	return s.user
 }
 
-func (s *Store) Product() storage.ProductRepoI {
-	if s.product == nil {
-		s.product = NewProductRepo(s.db)
-	}
+func (s *Store) Session() storage.SessionRepoI {
+	return s.session
+}
 
+func (s *Store) Role() storage.RoleRepoI {
+	return s.role
+}
+
+func (s *Store) Product() storage.ProductRepoI {
 	return s.product
 }
 
 func (s *Store) Category() storage.CategoryRepoI {
-	if s.category == nil {
-		s.category = NewCategoryRepo(s.db)
-	}
-
 	return s.category
 }
 
 func (s *Store) Client() storage.ClientRepoI {
-	if s.client == nil {
-		s.client = NewClientRepo(s.db)
-	}
-
 	return s.client
 }
 
 func (s *Store) Order() storage.OrderRepoI {
-	if s.order == nil {
-		s.order = NewOrderRepo(s.db)
+	return s.order
+}
+
+// keysetPage narrows filter to the rows after cursor and returns the
+// ORDER BY/LIMIT tail of a keyset page over (created_at, id), newest
+// first. One row more than the page is fetched to tell whether there is
+// a next page.
+func keysetPage(filter *helper.QueryBuilder, alias, cursor string, limit int) (string, error) {
+	if len(cursor) > 0 {
+		createdAt, id, err := helper.DecodeCursor(cursor)
+		if err != nil {
+			return "", err
+		}
+
+		filter.Where(fmt.Sprintf("(%[1]screated_at, %[1]sid) < (?::timestamp, ?::uuid)", alias), createdAt, id)
 	}
 
-	return s.order
+	return fmt.Sprintf(" ORDER BY %[1]screated_at DESC, %[1]sid DESC LIMIT %[2]d", alias, keysetLimit(limit)+1), nil
+}
+
+// keysetLimit is the page size of a keyset page, the rows past it only
+// tell that there is a next page.
+func keysetLimit(limit int) int {
+	if limit <= 0 {
+		return 10
+	}
+	return limit
+}
+
+// fullTextSearch narrows filter to the rows whose vector matches search,
+// a web search style query ("quoted phrase", -exclusion, or). It returns
+// the rank and highlighted snippet select columns, which are constants
+// when there is nothing to search.
+func fullTextSearch(filter *helper.QueryBuilder, search, vector, document string) (string, string) {
+	if len(search) == 0 {
+		return "0::real", "''"
+	}
+
+	query := "websearch_to_tsquery('simple', " + filter.Arg(search) + ")"
+
+	filter.Where(vector + " @@ " + query)
+
+	return "ts_rank(" + vector + ", " + query + ")",
+		"ts_headline('simple', " + document + ", " + query + ", 'StartSel=<b>, StopSel=</b>, MaxFragments=2')"
+}
+
+// versioned lists the tables with a version column. Only the writes
+// clients ask for bump it, the stock and totals kept up to date along
+// with orders do not.
+var versioned = map[string]bool{
+	"category": true,
+	"product":  true,
+	"client":   true,
+	"orders":   true,
+}
+
+// patchRow updates only the given columns of a live row of table, when
+// version is set only if the row still has it. The column names come from
+// the repositories, never from the request.
+func patchRow(ctx context.Context, db DB, table, id string, version int, columns map[string]interface{}) (int64, error) {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	q := helper.NewQueryBuilder()
+
+	sets := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		sets = append(sets, name+" = "+q.Arg(columns[name]))
+	}
+	sets = append(sets, "updated_at = now()")
+	if versioned[table] {
+		sets = append(sets, "version = version + 1")
+	}
+
+	q.Where("id = ?", id).Where("deleted_at IS NULL")
+	if version > 0 {
+		q.Where("version = ?", version)
+	}
+
+	query := "UPDATE " + table + " SET " + strings.Join(sets, ", ") + q.WhereClause()
+
+	result, err := db.Exec(ctx, query, q.Args()...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkVersion(ctx, db, table, id, version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// checkVersion tells why a write conditional on version touched no row of
+// table: storage.ErrVersionMismatch when the live row is there with another
+// version, nil when it is missing and the caller reports that on its own.
+func checkVersion(ctx context.Context, db DB, table, id string, version int, rowsAffected int64) error {
+	if rowsAffected > 0 || version <= 0 {
+		return nil
+	}
+
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return storage.ErrVersionMismatch
+	}
+
+	return nil
 }
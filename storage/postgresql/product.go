@@ -5,16 +5,16 @@ import (
 	"app/pkg/helper"
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 type productRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewProductRepo(db *pgxpool.Pool) *productRepo {
+func NewProductRepo(db DB) *productRepo {
 	return &productRepo{
 		db: db,
 	}
@@ -45,7 +45,7 @@ func (r *productRepo) Create(ctx context.Context, req *models.CreateProduct) (st
 		id,
 		req.Name,
 		req.CategoryId,
-		req.Description,
+		helper.NewNullString(req.Description),
 		req.Price,
 		req.Quantity,
 	)
@@ -75,18 +75,20 @@ func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey
 			CAST(c.created_at::timestamp AS VARCHAR),
 			CAST(c.updated_at::timestamp AS VARCHAR),
 			
-			p.description,
+			COALESCE(p.description, ''),
 			p.price,
 			p.quantity,
 			CAST(p.created_at::timestamp AS VARCHAR),
-			CAST(p.updated_at::timestamp AS VARCHAR)
+			CAST(p.updated_at::timestamp AS VARCHAR),
+			p.version,
+			COALESCE(CAST(p.deleted_at::timestamp AS VARCHAR), '')
 		FROM product AS p
 		JOIN category AS c ON c.id = p.category_id
-		WHERE p.id = $1
+		WHERE p.id = $1 AND (p.deleted_at IS NULL OR $2)
 	`
 	product.CategoryData = &models.Category{}
 
-	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+	err := r.db.QueryRow(ctx, query, req.Id, req.IncludeDeleted).Scan(
 		&product.Id,
 		&product.Name,
 		&product.CategoryId,
@@ -99,6 +101,8 @@ func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey
 		&product.Quantity,
 		&product.CreatedAt,
 		&product.UpdatedAt,
+		&product.Version,
+		&product.DeletedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -107,20 +111,41 @@ func (r *productRepo) GetByID(ctx context.Context, req *models.ProductPrimaryKey
 	return &product, nil
 }
 
+// productSortColumns whitelists the columns GetList may order by, keyed by
+// models.ProductSortFields.
+var productSortColumns = map[string]string{
+	"name":       "p.name",
+	"price":      "p.price",
+	"quantity":   "p.quantity",
+	"created_at": "p.created_at",
+}
+
 func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductRequest) (resp *models.GetListProductResponse, err error) {
 
 	resp = &models.GetListProductResponse{}
 
 	var (
-		query  string
-		filter = " WHERE TRUE "
-		offset = " OFFSET 0"
-		limit  = " LIMIT 10"
+		query       string
+		countColumn = "COUNT(*) OVER()"
+		filter      = helper.NewQueryBuilder()
+		offset      = " OFFSET 0"
+		limit       = " LIMIT 10"
 	)
 
+	// keyset pages skip the window count, which is what makes deep offset
+	// pages slow
+	if req.Keyset {
+		countColumn = "0"
+	}
+
+	rankColumn, highlightColumn := fullTextSearch(filter, req.Search, "p.search_vector",
+		"COALESCE(p.name, '') || ' ' || COALESCE(p.description, '')")
+
 	query = `
 	SELECT
-		COUNT(*) OVER(),
+		` + countColumn + `,
+		` + rankColumn + ` AS search_rank,
+		` + highlightColumn + `,
 		p.id, 
 		p.name, 
 
@@ -130,17 +155,68 @@ func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductReq
 		CAST(c.created_at::timestamp AS VARCHAR),
 		CAST(c.updated_at::timestamp AS VARCHAR),
 
-		p.description,
+		COALESCE(p.description, ''),
 		p.price,
 		p.quantity,
 		CAST(p.created_at::timestamp AS VARCHAR),
-		CAST(p.updated_at::timestamp AS VARCHAR)
+		CAST(p.updated_at::timestamp AS VARCHAR),
+		p.version,
+		COALESCE(CAST(p.deleted_at::timestamp AS VARCHAR), '')
 	FROM product AS p
 	JOIN category AS c ON c.id = p.category_id
 	`
 
-	if len(req.Search) > 0 {
-		filter += " AND name ILIKE '%' || '" + req.Search + "' || '%' "
+	if !req.IncludeDeleted {
+		filter.Where("p.deleted_at IS NULL")
+	}
+
+	if len(req.CategoryIds) > 0 && req.IncludeDescendants {
+		filter.Where(`p.category_id IN (
+			WITH RECURSIVE subtree AS (
+				SELECT id FROM category WHERE id = ANY(?::uuid[])
+				UNION
+				SELECT c.id FROM category AS c JOIN subtree AS s ON c.parent_id = s.id
+				WHERE c.deleted_at IS NULL
+			)
+			SELECT id FROM subtree
+		)`, req.CategoryIds)
+	} else if len(req.CategoryIds) > 0 {
+		filter.Where("p.category_id = ANY(?::uuid[])", req.CategoryIds)
+	}
+
+	if req.MinPrice != nil {
+		filter.Where("p.price >= ?", *req.MinPrice)
+	}
+
+	if req.MaxPrice != nil {
+		filter.Where("p.price <= ?", *req.MaxPrice)
+	}
+
+	if req.InStock {
+		filter.Where("p.quantity > 0")
+	}
+
+	if len(req.CreatedFrom) > 0 {
+		filter.Where("p.created_at >= ?::timestamp", req.CreatedFrom)
+	}
+
+	if len(req.CreatedTo) > 0 {
+		filter.Where("p.created_at <= ?::timestamp", req.CreatedTo)
+	}
+
+	sortColumn, ok := productSortColumns[req.SortBy]
+	if !ok {
+		sortColumn, req.Order = "p.created_at", "desc"
+
+		// without an explicit sort the best matches come first
+		if len(req.Search) > 0 {
+			sortColumn = "search_rank"
+		}
+	}
+
+	sortOrder := " ASC"
+	if strings.EqualFold(req.Order, "desc") {
+		sortOrder = " DESC"
 	}
 
 	if req.Offset > 0 {
@@ -151,9 +227,18 @@ func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductReq
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	query += filter + offset + limit
+	tail := " ORDER BY " + sortColumn + sortOrder + ", p.id" + offset + limit
+
+	if req.Keyset {
+		tail, err = keysetPage(filter, "p.", req.Cursor, req.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query += filter.WhereClause() + tail
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +249,8 @@ func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductReq
 		product.CategoryData = &models.Category{}
 		err = rows.Scan(
 			&resp.Count,
+			&product.Rank,
+			&product.Highlight,
 			&product.Id,
 			&product.Name,
 			&product.CategoryId,
@@ -176,6 +263,8 @@ func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductReq
 			&product.Quantity,
 			&product.CreatedAt,
 			&product.UpdatedAt,
+			&product.Version,
+			&product.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -184,6 +273,13 @@ func (r *productRepo) GetList(ctx context.Context, req *models.GetListProductReq
 		resp.Products = append(resp.Products, &product)
 	}
 
+	if req.Keyset && len(resp.Products) > keysetLimit(req.Limit) {
+		resp.Products = resp.Products[:keysetLimit(req.Limit)]
+
+		last := resp.Products[len(resp.Products)-1]
+		resp.NextCursor = helper.EncodeCursor(last.CreatedAt, last.Id)
+	}
+
 	return resp, nil
 }
 
@@ -197,21 +293,22 @@ func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (in
 		UPDATE
 		product
 		SET
-			id = :id, 
+			version = version + 1,
 			name = :name, 
 			category_id = :category_id,
 			description = :description,
 			price = :price,
 			quantity = :quantity,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL AND (:version = 0 OR version = :version)
 	`
 
 	params = map[string]interface{}{
 		"id":          req.Id,
+		"version":     req.Version,
 		"name":        req.Name,
 		"category_id": req.CategoryId,
-		"description": req.Description,
+		"description": helper.NewNullString(req.Description),
 		"price":       req.Price,
 		"quantity":    req.Quantity,
 	}
@@ -223,19 +320,92 @@ func (r *productRepo) Update(ctx context.Context, req *models.UpdateProduct) (in
 		return 0, err
 	}
 
+	err = checkVersion(ctx, r.db, "product", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+
 	return result.RowsAffected(), nil
 }
 
+func (r *productRepo) Patch(ctx context.Context, req *models.PatchProduct) (int64, error) {
+	columns := map[string]interface{}{}
+
+	if req.Name != nil {
+		columns["name"] = *req.Name
+	}
+	if req.CategoryId != nil {
+		columns["category_id"] = *req.CategoryId
+	}
+	if req.Description != nil {
+		// a merge patch null clears the description
+		columns["description"] = helper.NewNullString(*req.Description)
+	}
+	if req.Price != nil {
+		columns["price"] = *req.Price
+	}
+	if req.Quantity != nil {
+		columns["quantity"] = *req.Quantity
+	}
+
+	return patchRow(ctx, r.db, "product", req.Id, req.Version, columns)
+}
+
 func (r *productRepo) Delete(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
 	query := `
-		DELETE 
-		FROM product
-		WHERE id = $1
+		UPDATE
+		product
+		SET
+			version = version + 1,
+			deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id, req.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	err = checkVersion(ctx, r.db, "product", req.Id, req.Version, result.RowsAffected())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (r *productRepo) Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error) {
+	query := `
+		UPDATE
+		product
+		SET
+			version = version + 1,
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE id = $1 AND deleted_at IS NOT NULL
 	`
 
 	result, err := r.db.Exec(ctx, query, req.Id)
 	if err != nil {
 		return 0, err
 	}
+
+	return result.RowsAffected(), nil
+}
+
+// Purge removes the products deleted more than req.Days days ago that no order
+// line refers to any more.
+func (r *productRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	query := `
+		DELETE
+		FROM product
+		WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+			AND NOT EXISTS (SELECT 1 FROM order_products WHERE product_id = product.id)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Days)
+	if err != nil {
+		return 0, err
+	}
+
 	return result.RowsAffected(), nil
 }
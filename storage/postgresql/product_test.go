@@ -4,6 +4,8 @@ import (
 	"app/api/models"
 	"context"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestCreateProduct(t *testing.T) {
@@ -164,3 +166,47 @@ func TestDeleteProduct(t *testing.T) {
 		})
 	}
 }
+
+func TestPatchProductNull(t *testing.T) {
+	ctx := context.Background()
+
+	categoryId, err := testStore.Category().Create(ctx, &models.CreateCategory{Name: "Patch Null " + uuid.NewString()})
+	if err != nil {
+		t.Fatalf("create category: got: %v", err)
+	}
+
+	id, err := productTestRepo.Create(ctx, &models.CreateProduct{
+		Name:        "Patch Null",
+		CategoryId:  categoryId,
+		Description: "Description testing",
+		Price:       200,
+		Quantity:    10,
+	})
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	// a merge patch null reaches the repository as an empty value
+	description := ""
+	rows, err := productTestRepo.Patch(ctx, &models.PatchProduct{Id: id, Description: &description})
+	if err != nil || rows != 1 {
+		t.Fatalf("patch: got: %d rows, %v", rows, err)
+	}
+
+	var isNull bool
+	err = testStore.db.QueryRow(ctx, "SELECT description IS NULL FROM product WHERE id = $1", id).Scan(&isNull)
+	if err != nil {
+		t.Fatalf("select: got: %v", err)
+	}
+	if !isNull {
+		t.Errorf("description: got: not NULL, expected: NULL")
+	}
+
+	product, err := productTestRepo.GetByID(ctx, &models.ProductPrimaryKey{Id: id})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if product.Description != "" {
+		t.Errorf("description: got: %q, expected: empty", product.Description)
+	}
+}
@@ -0,0 +1,188 @@
+package postgresql
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+	"fmt"
+)
+
+type roleRepo struct {
+	db DB
+}
+
+func NewRoleRepo(db DB) *roleRepo {
+	return &roleRepo{
+		db: db,
+	}
+}
+
+func (r *roleRepo) GetByID(ctx context.Context, req *models.RolePrimaryKey) (*models.Role, error) {
+
+	var (
+		query string
+		role  models.Role
+		where = " WHERE r.id = $1"
+		key   = req.Id
+	)
+
+	if len(req.Name) > 0 {
+		where = " WHERE r.name = $1"
+		key = req.Name
+	}
+
+	query = `
+		SELECT
+			r.id,
+			r.name,
+			COALESCE(r.description, ''),
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}'),
+			CAST(r.created_at::timestamp AS VARCHAR),
+			COALESCE(CAST(r.updated_at::timestamp AS VARCHAR), '')
+		FROM roles AS r
+		LEFT JOIN role_permissions AS rp ON rp.role_id = r.id
+		LEFT JOIN permissions AS p ON p.id = rp.permission_id
+	` + where + `
+		GROUP BY r.id
+	`
+
+	err := r.db.QueryRow(ctx, query, key).Scan(
+		&role.Id,
+		&role.Name,
+		&role.Description,
+		&role.Permissions,
+		&role.CreatedAt,
+		&role.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (r *roleRepo) GetList(ctx context.Context, req *models.GetListRoleRequest) (resp *models.GetListRoleResponse, err error) {
+
+	resp = &models.GetListRoleResponse{}
+
+	var (
+		query  string
+		filter = helper.NewQueryBuilder()
+		offset = " OFFSET 0"
+		limit  = " LIMIT 10"
+	)
+
+	query = `
+		SELECT
+			COUNT(*) OVER(),
+			r.id,
+			r.name,
+			COALESCE(r.description, ''),
+			COALESCE(ARRAY_AGG(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}'),
+			CAST(r.created_at::timestamp AS VARCHAR),
+			COALESCE(CAST(r.updated_at::timestamp AS VARCHAR), '')
+		FROM roles AS r
+		LEFT JOIN role_permissions AS rp ON rp.role_id = r.id
+		LEFT JOIN permissions AS p ON p.id = rp.permission_id
+	`
+
+	filter.Search(req.Search, "r.name")
+
+	if req.Offset > 0 {
+		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
+	}
+
+	if req.Limit > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
+	}
+
+	query += filter.WhereClause() + " GROUP BY r.id ORDER BY r.name" + offset + limit
+
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role models.Role
+		err = rows.Scan(
+			&resp.Count,
+			&role.Id,
+			&role.Name,
+			&role.Description,
+			&role.Permissions,
+			&role.CreatedAt,
+			&role.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Roles = append(resp.Roles, &role)
+	}
+
+	return resp, nil
+}
+
+func (r *roleRepo) GetUserRoles(ctx context.Context, req *models.UserPrimaryKey) (*models.UserRoles, error) {
+
+	var (
+		query     string
+		userRoles = models.UserRoles{UserId: req.Id}
+	)
+
+	query = `
+		SELECT
+			COALESCE(ARRAY_AGG(DISTINCT r.name) FILTER (WHERE r.name IS NOT NULL), '{}'),
+			COALESCE(ARRAY_AGG(DISTINCT p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM user_roles AS ur
+		JOIN roles AS r ON r.id = ur.role_id
+		LEFT JOIN role_permissions AS rp ON rp.role_id = r.id
+		LEFT JOIN permissions AS p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+	`
+
+	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+		&userRoles.Roles,
+		&userRoles.Permissions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userRoles, nil
+}
+
+func (r *roleRepo) AssignRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	query := `
+		INSERT INTO user_roles(
+			user_id,
+			role_id
+		)
+		SELECT $1, id FROM roles WHERE name = $2
+		ON CONFLICT DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, req.UserId, req.Role)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *roleRepo) RevokeRole(ctx context.Context, req *models.UserRole) (int64, error) {
+	query := `
+		DELETE
+		FROM user_roles
+		WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2)
+	`
+
+	result, err := r.db.Exec(ctx, query, req.UserId, req.Role)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,164 @@
+package postgresql
+
+import (
+	"app/api/models"
+	"app/pkg/helper"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type sessionRepo struct {
+	db DB
+}
+
+func NewSessionRepo(db DB) *sessionRepo {
+	return &sessionRepo{
+		db: db,
+	}
+}
+
+func (r *sessionRepo) Create(ctx context.Context, req *models.CreateSession) (string, error) {
+	var (
+		query string
+		id    string
+	)
+	id = uuid.NewString()
+
+	query = `
+		INSERT INTO user_sessions(
+			id,
+			user_id,
+			family_id,
+			refresh_token_hash,
+			user_agent,
+			ip_address,
+			expires_at,
+			updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, now() + $7 * INTERVAL '1 second', now())
+	`
+	_, err := r.db.Exec(ctx, query,
+		id,
+		req.UserId,
+		req.FamilyId,
+		req.RefreshTokenHash,
+		helper.NewNullString(req.UserAgent),
+		helper.NewNullString(req.IpAddress),
+		int64(req.TTL.Seconds()),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
+
+func (r *sessionRepo) GetByID(ctx context.Context, req *models.SessionPrimaryKey) (*models.Session, error) {
+
+	var (
+		query   string
+		session models.Session
+		where   = " WHERE id = $1"
+		key     = req.Id
+	)
+
+	if len(req.RefreshTokenHash) > 0 {
+		where = " WHERE refresh_token_hash = $1"
+		key = req.RefreshTokenHash
+	}
+
+	query = `
+		SELECT
+			id,
+			user_id,
+			family_id,
+			COALESCE(user_agent, ''),
+			COALESCE(ip_address, ''),
+			CAST(expires_at::timestamp AS VARCHAR),
+			COALESCE(CAST(revoked_at::timestamp AS VARCHAR), ''),
+			COALESCE(CAST(replaced_by AS VARCHAR), ''),
+			expires_at <= now(),
+			revoked_at IS NOT NULL,
+			CAST(created_at::timestamp AS VARCHAR),
+			COALESCE(CAST(updated_at::timestamp AS VARCHAR), '')
+		FROM user_sessions
+	` + where
+
+	err := r.db.QueryRow(ctx, query, key).Scan(
+		&session.Id,
+		&session.UserId,
+		&session.FamilyId,
+		&session.UserAgent,
+		&session.IpAddress,
+		&session.ExpiresAt,
+		&session.RevokedAt,
+		&session.ReplacedBy,
+		&session.Expired,
+		&session.Revoked,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// Revoke revokes a single active session. Zero rows affected means the
+// session was already revoked, which callers treat as token reuse.
+func (r *sessionRepo) Revoke(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	query := `
+		UPDATE
+		user_sessions
+		SET
+			revoked_at = now(),
+			replaced_by = $2,
+			updated_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id, helper.NewNullString(req.ReplacedBy))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *sessionRepo) RevokeFamily(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	query := `
+		UPDATE
+		user_sessions
+		SET
+			revoked_at = now(),
+			updated_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.FamilyId)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *sessionRepo) RevokeAll(ctx context.Context, req *models.RevokeSession) (int64, error) {
+	query := `
+		UPDATE
+		user_sessions
+		SET
+			revoked_at = now(),
+			updated_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.UserId)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -7,14 +7,13 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 type userRepo struct {
-	db *pgxpool.Pool
+	db DB
 }
 
-func NewUserRepo(db *pgxpool.Pool) *userRepo {
+func NewUserRepo(db DB) *userRepo {
 	return &userRepo{
 		db: db,
 	}
@@ -62,7 +61,7 @@ func (r *userRepo) GetByID(ctx context.Context, req *models.UserPrimaryKey) (*mo
 	)
 
 	if len(req.Login) > 0 {
-		err := r.db.QueryRow(ctx, "SELECT id FROM users WHERE login = $1", req.Login).Scan(&req.Id)
+		err := r.db.QueryRow(ctx, "SELECT id FROM users WHERE login = $1 AND deleted_at IS NULL", req.Login).Scan(&req.Id)
 		if err != nil {
 			return nil, err
 		}
@@ -74,23 +73,23 @@ func (r *userRepo) GetByID(ctx context.Context, req *models.UserPrimaryKey) (*mo
 			first_name,
 			last_name,
 			login,
-			password,
 			phone_number,
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM users
-		WHERE id = $1
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
 	`
 
-	err := r.db.QueryRow(ctx, query, req.Id).Scan(
+	err := r.db.QueryRow(ctx, query, req.Id, req.IncludeDeleted).Scan(
 		&user.Id,
 		&user.FirstName,
 		&user.LastName,
 		&user.Login,
-		&user.Password,
 		&user.PhoneNumber,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.DeletedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -99,13 +98,47 @@ func (r *userRepo) GetByID(ctx context.Context, req *models.UserPrimaryKey) (*mo
 	return &user, nil
 }
 
+func (r *userRepo) GetCredentials(ctx context.Context, req *models.UserPrimaryKey) (*models.UserCredentials, error) {
+
+	var (
+		query       string
+		credentials models.UserCredentials
+		where       = " WHERE id = $1 AND deleted_at IS NULL"
+		key         = req.Id
+	)
+
+	if len(req.Login) > 0 {
+		where = " WHERE login = $1 AND deleted_at IS NULL"
+		key = req.Login
+	}
+
+	query = `
+		SELECT
+			id,
+			login,
+			password
+		FROM users
+	` + where
+
+	err := r.db.QueryRow(ctx, query, key).Scan(
+		&credentials.Id,
+		&credentials.Login,
+		&credentials.Password,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credentials, nil
+}
+
 func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest) (resp *models.GetListUserResponse, err error) {
 
 	resp = &models.GetListUserResponse{}
 
 	var (
 		query  string
-		filter = " WHERE TRUE "
+		filter = helper.NewQueryBuilder()
 		offset = " OFFSET 0"
 		limit  = " LIMIT 10"
 	)
@@ -117,17 +150,19 @@ func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest)
 			first_name,
 			last_name,
 			login,
-			password,
 			phone_number,
 			CAST(created_at::timestamp AS VARCHAR),
-			CAST(updated_at::timestamp AS VARCHAR)
+			CAST(updated_at::timestamp AS VARCHAR),
+			COALESCE(CAST(deleted_at::timestamp AS VARCHAR), '')
 		FROM users
 	`
 
-	if len(req.Search) > 0 {
-		filter += " AND name ILIKE '%' || '" + req.Search + "' || '%' "
+	if !req.IncludeDeleted {
+		filter.Where("deleted_at IS NULL")
 	}
 
+	filter.Search(req.Search, "first_name", "last_name", "login", "phone_number")
+
 	if req.Offset > 0 {
 		offset = fmt.Sprintf(" OFFSET %d", req.Offset)
 	}
@@ -136,9 +171,9 @@ func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest)
 		limit = fmt.Sprintf(" LIMIT %d", req.Limit)
 	}
 
-	query += filter + offset + limit
+	query += filter.WhereClause() + offset + limit
 
-	rows, err := r.db.Query(ctx, query)
+	rows, err := r.db.Query(ctx, query, filter.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -152,10 +187,10 @@ func (r *userRepo) GetList(ctx context.Context, req *models.GetListUserRequest)
 			&user.FirstName,
 			&user.LastName,
 			&user.Login,
-			&user.Password,
 			&user.PhoneNumber,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.DeletedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -177,14 +212,13 @@ func (r *userRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, e
 		UPDATE
 		users
 		SET
-			id = :id,
 			first_name = :first_name,
 			last_name = :last_name,
 			login = :login,
-			password = :password,
+			password = COALESCE(:password, password),
 			phone_number = :phone_number,
 			updated_at = now()
-		WHERE id = :id
+		WHERE id = :id AND deleted_at IS NULL
 	`
 
 	params = map[string]interface{}{
@@ -192,7 +226,7 @@ func (r *userRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, e
 		"first_name":   req.FirstName,
 		"last_name":    req.LastName,
 		"login":        req.Login,
-		"password":     req.Password,
+		"password":     helper.NewNullString(req.Password),
 		"phone_number": req.PhoneNumber,
 	}
 
@@ -206,11 +240,53 @@ func (r *userRepo) Update(ctx context.Context, req *models.UpdateUser) (int64, e
 	return result.RowsAffected(), nil
 }
 
+func (r *userRepo) Patch(ctx context.Context, req *models.PatchUser) (int64, error) {
+	columns := map[string]interface{}{}
+
+	if req.FirstName != nil {
+		columns["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		columns["last_name"] = *req.LastName
+	}
+	if req.Login != nil {
+		columns["login"] = *req.Login
+	}
+	if req.Password != nil {
+		columns["password"] = *req.Password
+	}
+	if req.PhoneNumber != nil {
+		columns["phone_number"] = *req.PhoneNumber
+	}
+
+	return patchRow(ctx, r.db, "users", req.Id, 0, columns)
+}
+
+func (r *userRepo) UpdatePassword(ctx context.Context, req *models.UpdateUserPassword) (int64, error) {
+	query := `
+		UPDATE
+		users
+		SET
+			password = $2,
+			updated_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id, req.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
 func (r *userRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
 	query := `
-		DELETE 
-		FROM users
-		WHERE id = $1
+		UPDATE
+		users
+		SET
+			deleted_at = now()
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 
 	result, err := r.db.Exec(ctx, query, req.Id)
@@ -220,3 +296,38 @@ func (r *userRepo) Delete(ctx context.Context, req *models.UserPrimaryKey) (int6
 
 	return result.RowsAffected(), nil
 }
+
+func (r *userRepo) Restore(ctx context.Context, req *models.UserPrimaryKey) (int64, error) {
+	query := `
+		UPDATE
+		users
+		SET
+			deleted_at = NULL,
+			updated_at = now()
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// Purge removes the users deleted more than req.Days days ago, their sessions and
+// roles go with them.
+func (r *userRepo) Purge(ctx context.Context, req *models.PurgeRequest) (int64, error) {
+	query := `
+		DELETE
+		FROM users
+		WHERE deleted_at < now() - $1 * INTERVAL '1 day'
+	`
+
+	result, err := r.db.Exec(ctx, query, req.Days)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected(), nil
+}
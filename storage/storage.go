@@ -7,18 +7,48 @@ import (
 
 type StorageI interface {
 	CloseDB()
+	// Ping checks that the database answers and reports its schema version
+	// and connections.
+	Ping(ctx context.Context) (*models.DatabaseHealth, error)
+	// WithTx runs fn with repositories bound to a single transaction, which
+	// is committed when fn returns nil and rolled back otherwise. Nested
+	// calls on the transactional storage use savepoints.
+	WithTx(ctx context.Context, fn func(tx StorageI) error) error
 	Product() ProductRepoI
 	Category() CategoryRepoI
 	Client() ClientRepoI
 	Order() OrderRepoI
 	User() UserRepoI
+	Session() SessionRepoI
+	Role() RoleRepoI
 }
 type UserRepoI interface {
 	Create(ctx context.Context, req *models.CreateUser) (string, error)
 	GetByID(ctx context.Context, req *models.UserPrimaryKey) (*models.User, error)
+	GetCredentials(ctx context.Context, req *models.UserPrimaryKey) (*models.UserCredentials, error)
 	GetList(ctx context.Context, req *models.GetListUserRequest) (resp *models.GetListUserResponse, err error)
 	Update(ctx context.Context, req *models.UpdateUser) (int64, error)
+	Patch(ctx context.Context, req *models.PatchUser) (int64, error)
+	UpdatePassword(ctx context.Context, req *models.UpdateUserPassword) (int64, error)
 	Delete(ctx context.Context, req *models.UserPrimaryKey) (int64, error)
+	Restore(ctx context.Context, req *models.UserPrimaryKey) (int64, error)
+	Purge(ctx context.Context, req *models.PurgeRequest) (int64, error)
+}
+
+type SessionRepoI interface {
+	Create(ctx context.Context, req *models.CreateSession) (string, error)
+	GetByID(ctx context.Context, req *models.SessionPrimaryKey) (*models.Session, error)
+	Revoke(ctx context.Context, req *models.RevokeSession) (int64, error)
+	RevokeFamily(ctx context.Context, req *models.RevokeSession) (int64, error)
+	RevokeAll(ctx context.Context, req *models.RevokeSession) (int64, error)
+}
+
+type RoleRepoI interface {
+	GetByID(ctx context.Context, req *models.RolePrimaryKey) (*models.Role, error)
+	GetList(ctx context.Context, req *models.GetListRoleRequest) (*models.GetListRoleResponse, error)
+	GetUserRoles(ctx context.Context, req *models.UserPrimaryKey) (*models.UserRoles, error)
+	AssignRole(ctx context.Context, req *models.UserRole) (int64, error)
+	RevokeRole(ctx context.Context, req *models.UserRole) (int64, error)
 }
 
 type ProductRepoI interface {
@@ -26,7 +56,10 @@ type ProductRepoI interface {
 	GetByID(context.Context, *models.ProductPrimaryKey) (*models.Product, error)
 	GetList(context.Context, *models.GetListProductRequest) (*models.GetListProductResponse, error)
 	Update(ctx context.Context, req *models.UpdateProduct) (int64, error)
+	Patch(ctx context.Context, req *models.PatchProduct) (int64, error)
 	Delete(ctx context.Context, req *models.ProductPrimaryKey) (int64, error)
+	Restore(ctx context.Context, req *models.ProductPrimaryKey) (int64, error)
+	Purge(ctx context.Context, req *models.PurgeRequest) (int64, error)
 }
 
 type CategoryRepoI interface {
@@ -35,6 +68,10 @@ type CategoryRepoI interface {
 	GetList(context.Context, *models.GetListCategoryRequest) (*models.GetListCategoryResponse, error)
 	Delete(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error)
 	Update(ctx context.Context, req *models.UpdateCategory) (int64, error)
+	Patch(ctx context.Context, req *models.PatchCategory) (int64, error)
+	GetTree(ctx context.Context) (*models.GetCategoryTreeResponse, error)
+	Restore(ctx context.Context, req *models.CategoryPrimaryKey) (int64, error)
+	Purge(ctx context.Context, req *models.PurgeRequest) (int64, error)
 }
 
 type ClientRepoI interface {
@@ -42,7 +79,10 @@ type ClientRepoI interface {
 	GetByID(ctx context.Context, req *models.ClientPrimaryKey) (*models.Client, error)
 	GetList(ctx context.Context, req *models.GetListClientRequest) (resp *models.GetListClientResponse, err error)
 	Update(ctx context.Context, req *models.UpdateClient) (int64, error)
+	Patch(ctx context.Context, req *models.PatchClient) (int64, error)
 	Delete(ctx context.Context, req *models.ClientPrimaryKey) (int64, error)
+	Restore(ctx context.Context, req *models.ClientPrimaryKey) (int64, error)
+	Purge(ctx context.Context, req *models.PurgeRequest) (int64, error)
 }
 
 type OrderRepoI interface {
@@ -50,7 +90,12 @@ type OrderRepoI interface {
 	GetByID(ctx context.Context, req *models.OrderPrimaryKey) (*models.Order, error)
 	GetList(ctx context.Context, req *models.GetListOrderRequest) (resp *models.GetListOrderResponse, err error)
 	Update(ctx context.Context, req *models.UpdateOrder) (int64, error)
+	Patch(ctx context.Context, req *models.PatchOrder) (int64, error)
+	UpdateStatus(ctx context.Context, req *models.UpdateOrderStatus) (int64, error)
+	GetHistory(ctx context.Context, req *models.OrderPrimaryKey) (*models.GetListOrderHistoryResponse, error)
 	Delete(ctx context.Context, req *models.OrderPrimaryKey) (int64, error)
 	AddOrderProduct(ctx context.Context, req *models.CreateOrderItem) (string, error)
 	RemoveOrderItem(ctx context.Context, req *models.OrderProductPrimaryKey) (int64, error)
+	Restore(ctx context.Context, req *models.OrderPrimaryKey) (int64, error)
+	Purge(ctx context.Context, req *models.PurgeRequest) (int64, error)
 }
@@ -0,0 +1,452 @@
+package storagetest
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func createCategory(ctx context.Context, strg storage.StorageI, name, parentId string) (string, error) {
+	return strg.Category().Create(ctx, &models.CreateCategory{Name: name, ParentId: parentId})
+}
+
+func createProduct(ctx context.Context, strg storage.StorageI, req *models.CreateProduct) (string, error) {
+	return strg.Product().Create(ctx, req)
+}
+
+func createClient(ctx context.Context, strg storage.StorageI, lastName string) (string, error) {
+	return strg.Client().Create(ctx, &models.CreateClient{
+		FirstName:   "Test Name",
+		LastName:    lastName,
+		PhoneNumber: "+998933791110",
+	})
+}
+
+func getClient(ctx context.Context, strg storage.StorageI, id string) (*models.Client, error) {
+	return strg.Client().GetByID(ctx, &models.ClientPrimaryKey{Id: id})
+}
+
+func getProduct(ctx context.Context, strg storage.StorageI, id string) (*models.Product, error) {
+	return strg.Product().GetByID(ctx, &models.ProductPrimaryKey{Id: id})
+}
+
+func testCategory(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+	name := unique("category")
+
+	rootId, err := createCategory(ctx, strg, name, "")
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	childId, err := createCategory(ctx, strg, name+"b", rootId)
+	if err != nil {
+		t.Fatalf("create child: got: %v", err)
+	}
+
+	_, err = createCategory(ctx, strg, name+"c", uuid.NewString())
+	expectErr(t, "create under missing parent", err, storage.ErrForeignKey)
+
+	root, err := strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: rootId})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if root.Name != name || root.ParentId != "" || root.Version != 1 {
+		t.Errorf("get: got: %+v", *root)
+	}
+
+	tests := []struct {
+		Name    string
+		Input   *models.UpdateCategory
+		WantErr error
+	}{
+		{
+			Name:    "move under itself",
+			Input:   &models.UpdateCategory{Id: rootId, ParentId: rootId, Name: name},
+			WantErr: storage.ErrCategoryCycle,
+		},
+		{
+			Name:    "move under descendant",
+			Input:   &models.UpdateCategory{Id: rootId, ParentId: childId, Name: name},
+			WantErr: storage.ErrCategoryCycle,
+		},
+		{
+			Name:    "stale version",
+			Input:   &models.UpdateCategory{Id: rootId, Name: name, Version: root.Version + 1},
+			WantErr: storage.ErrVersionMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		_, err = strg.Category().Update(ctx, test.Input)
+		expectErr(t, test.Name, err, test.WantErr)
+	}
+
+	rows, err := strg.Category().Update(ctx, &models.UpdateCategory{Id: rootId, Name: name + "a", Version: root.Version})
+	expectRows(t, "update", rows, err, 1)
+
+	rows, err = strg.Category().Update(ctx, &models.UpdateCategory{Id: uuid.NewString(), Name: name, Version: 1})
+	expectRows(t, "update missing", rows, err, 0)
+
+	patched := name
+	rows, err = strg.Category().Patch(ctx, &models.PatchCategory{Id: rootId, Name: &patched, Version: root.Version + 1})
+	expectRows(t, "patch", rows, err, 1)
+
+	root, err = strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: rootId})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if root.Name != name || root.Version != 3 {
+		t.Errorf("get patched: got: %+v", *root)
+	}
+
+	node := findCategory(t, strg, rootId)
+	if node == nil || len(node.Children) != 1 || node.Children[0].Id != childId {
+		t.Fatalf("tree: got: %+v", node)
+	}
+
+	rows, err = strg.Category().Delete(ctx, &models.CategoryPrimaryKey{Id: childId})
+	expectRows(t, "delete", rows, err, 1)
+
+	node = findCategory(t, strg, rootId)
+	if node == nil || len(node.Children) != 0 {
+		t.Errorf("tree without deleted: got: %+v", node)
+	}
+
+	list, err := strg.Category().GetList(ctx, &models.GetListCategoryRequest{Search: name})
+	if err != nil {
+		t.Fatalf("list: got: %v", err)
+	}
+	if list.Count != 1 || list.Categories[0].Id != rootId {
+		t.Errorf("list: got: %d categories, count %d", len(list.Categories), list.Count)
+	}
+
+	list, err = strg.Category().GetList(ctx, &models.GetListCategoryRequest{Search: name, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("list including deleted: got: %v", err)
+	}
+	if list.Count != 2 {
+		t.Errorf("list including deleted: got: count %d", list.Count)
+	}
+
+	// deleted categories still hold their subtree together
+	_, err = strg.Category().Update(ctx, &models.UpdateCategory{Id: rootId, ParentId: childId, Name: name})
+	expectErr(t, "move under deleted descendant", err, storage.ErrCategoryCycle)
+
+	// a subtree under a deleted category would vanish from the tree
+	otherId, err := createCategory(ctx, strg, unique("category"), "")
+	if err != nil {
+		t.Fatalf("create other: got: %v", err)
+	}
+
+	_, err = strg.Category().Update(ctx, &models.UpdateCategory{Id: otherId, ParentId: childId, Name: name})
+	expectErr(t, "move under deleted parent", err, storage.ErrForeignKey)
+
+	missingId := uuid.NewString()
+	_, err = strg.Category().Patch(ctx, &models.PatchCategory{Id: otherId, ParentId: &missingId})
+	expectErr(t, "move under missing parent", err, storage.ErrForeignKey)
+
+	_, err = createCategory(ctx, strg, name+"d", childId)
+	expectErr(t, "create under deleted parent", err, storage.ErrForeignKey)
+
+	rows, err = strg.Category().Restore(ctx, &models.CategoryPrimaryKey{Id: childId})
+	expectRows(t, "restore", rows, err, 1)
+
+	// rows deleted within the retention period stay
+	rows, err = strg.Category().Delete(ctx, &models.CategoryPrimaryKey{Id: childId})
+	expectRows(t, "delete", rows, err, 1)
+
+	_, err = strg.Category().Purge(ctx, &models.PurgeRequest{Days: 36500})
+	if err != nil {
+		t.Fatalf("purge: got: %v", err)
+	}
+
+	_, err = strg.Category().GetByID(ctx, &models.CategoryPrimaryKey{Id: childId, IncludeDeleted: true})
+	if err != nil {
+		t.Errorf("get after purge: got: %v", err)
+	}
+}
+
+// findCategory returns the node of the category tree with the id, searching
+// the roots only.
+func findCategory(t *testing.T, strg storage.StorageI, id string) *models.Category {
+	t.Helper()
+
+	tree, err := strg.Category().GetTree(context.Background())
+	if err != nil {
+		t.Fatalf("tree: got: %v", err)
+	}
+
+	for _, node := range tree.Categories {
+		if node.Id == id {
+			return node
+		}
+	}
+
+	return nil
+}
+
+func testProduct(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+	word := unique("product")
+
+	rootId, err := createCategory(ctx, strg, unique("category"), "")
+	if err != nil {
+		t.Fatalf("create category: got: %v", err)
+	}
+
+	childId, err := createCategory(ctx, strg, unique("category"), rootId)
+	if err != nil {
+		t.Fatalf("create category: got: %v", err)
+	}
+
+	inputs := []*models.CreateProduct{
+		{Name: "Cheap " + word, CategoryId: rootId, Description: "cheap", Price: 10, Quantity: 0},
+		{Name: "Middle", CategoryId: childId, Description: "middle " + word, Price: 20, Quantity: 5},
+		{Name: "Dear", CategoryId: childId, Description: "dear", Price: 30, Quantity: 5},
+	}
+
+	var ids []string
+	for _, input := range inputs {
+		id, err := createProduct(ctx, strg, input)
+		if err != nil {
+			t.Fatalf("create: got: %v", err)
+		}
+		ids = append(ids, id)
+
+		// keep created_at apart for the keyset pages
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	_, err = createProduct(ctx, strg, &models.CreateProduct{Name: word, CategoryId: uuid.NewString()})
+	expectErr(t, "create in missing category", err, storage.ErrForeignKey)
+
+	product, err := getProduct(ctx, strg, ids[1])
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if product.Price != 20 || product.CategoryData == nil || product.CategoryData.Id != childId {
+		t.Errorf("get: got: %+v", *product)
+	}
+
+	minPrice := 15.0
+
+	tests := []struct {
+		Name   string
+		Input  *models.GetListProductRequest
+		Output []string
+	}{
+		{
+			Name:   "category",
+			Input:  &models.GetListProductRequest{CategoryIds: []string{childId}, SortBy: "price"},
+			Output: []string{ids[1], ids[2]},
+		},
+		{
+			Name:   "descendants",
+			Input:  &models.GetListProductRequest{CategoryIds: []string{rootId}, IncludeDescendants: true, SortBy: "price", Order: "desc"},
+			Output: []string{ids[2], ids[1], ids[0]},
+		},
+		{
+			Name:   "min price",
+			Input:  &models.GetListProductRequest{CategoryIds: []string{rootId}, IncludeDescendants: true, MinPrice: &minPrice, SortBy: "price"},
+			Output: []string{ids[1], ids[2]},
+		},
+		{
+			Name:   "in stock",
+			Input:  &models.GetListProductRequest{CategoryIds: []string{rootId, childId}, InStock: true, SortBy: "name"},
+			Output: []string{ids[2], ids[1]},
+		},
+		{
+			Name:   "newest first",
+			Input:  &models.GetListProductRequest{CategoryIds: []string{rootId, childId}},
+			Output: []string{ids[2], ids[1], ids[0]},
+		},
+		{
+			Name:   "search ranks name above description",
+			Input:  &models.GetListProductRequest{Search: word},
+			Output: []string{ids[0], ids[1]},
+		},
+		{
+			Name:   "search excluding",
+			Input:  &models.GetListProductRequest{Search: word + " -cheap"},
+			Output: []string{ids[1]},
+		},
+		{
+			Name:   "search alternatives",
+			Input:  &models.GetListProductRequest{Search: word + " dear or " + word + " cheap"},
+			Output: []string{ids[0]},
+		},
+	}
+
+	for _, test := range tests {
+		list, err := strg.Product().GetList(ctx, test.Input)
+		if err != nil {
+			t.Fatalf("%s: got: %v", test.Name, err)
+		}
+
+		var got []string
+		for _, p := range list.Products {
+			got = append(got, p.Id)
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.Output, ",") || list.Count != len(test.Output) {
+			t.Errorf("%s: got: %v count %d, expected: %v", test.Name, got, list.Count, test.Output)
+		}
+	}
+
+	list, err := strg.Product().GetList(ctx, &models.GetListProductRequest{Search: word, Limit: 1})
+	if err != nil {
+		t.Fatalf("search: got: %v", err)
+	}
+	if len(list.Products) != 1 || !strings.Contains(list.Products[0].Highlight, "<b>"+word+"</b>") || list.Products[0].Rank <= 0 {
+		t.Errorf("search: got: %+v", list.Products)
+	}
+
+	// keyset pages
+	var (
+		cursor string
+		pages  [][]string
+	)
+	for {
+		list, err := strg.Product().GetList(ctx, &models.GetListProductRequest{
+			CategoryIds: []string{rootId, childId},
+			Limit:       2,
+			Cursor:      cursor,
+			Keyset:      true,
+		})
+		if err != nil {
+			t.Fatalf("keyset: got: %v", err)
+		}
+
+		var page []string
+		for _, p := range list.Products {
+			page = append(page, p.Id)
+		}
+		pages = append(pages, page)
+
+		cursor = list.NextCursor
+		if len(cursor) == 0 || len(pages) > 2 {
+			break
+		}
+	}
+	if fmt.Sprint(pages) != fmt.Sprint([][]string{{ids[2], ids[1]}, {ids[0]}}) {
+		t.Errorf("keyset: got: %v", pages)
+	}
+
+	rows, err := strg.Product().Delete(ctx, &models.ProductPrimaryKey{Id: ids[0], Version: product.Version + 1})
+	expectErr(t, "delete stale version", err, storage.ErrVersionMismatch)
+
+	quantity := 7
+	rows, err = strg.Product().Patch(ctx, &models.PatchProduct{Id: ids[1], Quantity: &quantity, Version: product.Version})
+	expectRows(t, "patch", rows, err, 1)
+
+	product, err = getProduct(ctx, strg, ids[1])
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if product.Quantity != 7 || product.Price != 20 || product.Version != 2 {
+		t.Errorf("get patched: got: %+v", *product)
+	}
+
+	rows, err = strg.Product().Delete(ctx, &models.ProductPrimaryKey{Id: ids[0]})
+	expectRows(t, "delete", rows, err, 1)
+
+	_, err = getProduct(ctx, strg, ids[0])
+	expectErr(t, "get deleted", err, storage.ErrNotFound)
+
+	rows, err = strg.Product().Patch(ctx, &models.PatchProduct{Id: ids[0], Quantity: &quantity})
+	expectRows(t, "patch deleted", rows, err, 0)
+
+	rows, err = strg.Product().Restore(ctx, &models.ProductPrimaryKey{Id: ids[0]})
+	expectRows(t, "restore", rows, err, 1)
+}
+
+func testClient(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+	lastName := unique("client")
+	phone := fmt.Sprintf("+998 %09d", time.Now().UnixNano()%1000000000)
+
+	id, err := strg.Client().Create(ctx, &models.CreateClient{
+		FirstName:   "Test Name",
+		LastName:    lastName,
+		PhoneNumber: phone,
+	})
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	client, err := getClient(ctx, strg, id)
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if client.LastName != lastName || client.PhoneNumber != phone || client.Version != 1 {
+		t.Errorf("get: got: %+v", *client)
+	}
+
+	// the phone number is found as written and as bare digits
+	searches := []string{lastName, "test " + lastName, lastName + " " + strings.NewReplacer("+", "", " ", "").Replace(phone)}
+	for _, search := range searches {
+		list, err := strg.Client().GetList(ctx, &models.GetListClientRequest{Search: search})
+		if err != nil {
+			t.Fatalf("search %q: got: %v", search, err)
+		}
+		if list.Count != 1 || list.Clients[0].Id != id || !strings.Contains(list.Clients[0].Highlight, "<b>"+lastName+"</b>") {
+			t.Errorf("search %q: got: %+v", search, list.Clients)
+		}
+	}
+
+	firstName := "Patched"
+	rows, err := strg.Client().Patch(ctx, &models.PatchClient{Id: id, FirstName: &firstName, Version: 2})
+	expectErr(t, "patch stale version", err, storage.ErrVersionMismatch)
+	if rows != 0 {
+		t.Errorf("patch stale version: got: %d rows", rows)
+	}
+
+	rows, err = strg.Client().Patch(ctx, &models.PatchClient{Id: id, FirstName: &firstName, Version: 1})
+	expectRows(t, "patch", rows, err, 1)
+
+	rows, err = strg.Client().Update(ctx, &models.UpdateClient{Id: id, FirstName: "Updated", LastName: lastName, PhoneNumber: phone})
+	expectRows(t, "update", rows, err, 1)
+
+	client, err = getClient(ctx, strg, id)
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if client.FirstName != "Updated" || client.Version != 3 {
+		t.Errorf("get updated: got: %+v", *client)
+	}
+
+	rows, err = strg.Client().Delete(ctx, &models.ClientPrimaryKey{Id: id, Version: 3})
+	expectRows(t, "delete", rows, err, 1)
+
+	rows, err = strg.Client().Delete(ctx, &models.ClientPrimaryKey{Id: id, Version: 3})
+	expectRows(t, "delete deleted", rows, err, 0)
+
+	_, err = getClient(ctx, strg, id)
+	expectErr(t, "get deleted", err, storage.ErrNotFound)
+
+	list, err := strg.Client().GetList(ctx, &models.GetListClientRequest{Search: lastName})
+	if err != nil {
+		t.Fatalf("list: got: %v", err)
+	}
+	if len(list.Clients) != 0 || list.Count != 0 {
+		t.Errorf("list without deleted: got: %+v", list.Clients)
+	}
+
+	rows, err = strg.Client().Restore(ctx, &models.ClientPrimaryKey{Id: id})
+	expectRows(t, "restore", rows, err, 1)
+
+	client, err = getClient(ctx, strg, id)
+	if err != nil {
+		t.Fatalf("get restored: got: %v", err)
+	}
+	if len(client.DeletedAt) != 0 || client.Version != 5 {
+		t.Errorf("get restored: got: %+v", *client)
+	}
+}
@@ -0,0 +1,301 @@
+package storagetest
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testOrder(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	clientId, err := createClient(ctx, strg, unique("order"))
+	if err != nil {
+		t.Fatalf("create client: got: %v", err)
+	}
+
+	categoryId, err := createCategory(ctx, strg, unique("order"), "")
+	if err != nil {
+		t.Fatalf("create category: got: %v", err)
+	}
+
+	productId, err := createProduct(ctx, strg, &models.CreateProduct{
+		Name:       unique("order"),
+		CategoryId: categoryId,
+		Price:      10,
+		Quantity:   5,
+	})
+	if err != nil {
+		t.Fatalf("create product: got: %v", err)
+	}
+
+	userId, err := createUser(ctx, strg, unique("order"))
+	if err != nil {
+		t.Fatalf("create user: got: %v", err)
+	}
+
+	_, err = strg.Order().Create(ctx, &models.CreateOrder{ClientId: uuid.NewString()})
+	expectErr(t, "create for missing client", err, storage.ErrForeignKey)
+
+	id, err := strg.Order().Create(ctx, &models.CreateOrder{ClientId: clientId, CreatedBy: userId})
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	order, err := strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: id, WithHistory: true})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if order.Status != models.OrderStatusNew || order.OrderProducts == nil || len(order.OrderProducts) != 0 ||
+		order.ClientData == nil || order.ClientData.Id != clientId || len(order.History) != 1 || order.History[0].ChangedBy != userId {
+		t.Errorf("get: got: %+v", *order)
+	}
+
+	items := []struct {
+		Name    string
+		Input   *models.CreateOrderItem
+		WantErr error
+	}{
+		{
+			Name:    "missing order",
+			Input:   &models.CreateOrderItem{OrderId: uuid.NewString(), ProductId: productId, Quantity: 1},
+			WantErr: storage.ErrNotFound,
+		},
+		{
+			Name:    "missing product",
+			Input:   &models.CreateOrderItem{OrderId: id, ProductId: uuid.NewString(), Quantity: 1},
+			WantErr: storage.ErrNotFound,
+		},
+		{
+			Name:    "insufficient stock",
+			Input:   &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 6},
+			WantErr: storage.ErrInsufficientStock,
+		},
+		{
+			Name:    "zero quantity",
+			Input:   &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 0},
+			WantErr: storage.ErrCheckViolation,
+		},
+	}
+
+	for _, item := range items {
+		_, err = strg.Order().AddOrderProduct(ctx, item.Input)
+		expectErr(t, item.Name, err, item.WantErr)
+	}
+
+	before, err := getProduct(ctx, strg, productId)
+	if err != nil {
+		t.Fatalf("get product: got: %v", err)
+	}
+
+	lineId, err := strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 2})
+	if err != nil {
+		t.Fatalf("add line: got: %v", err)
+	}
+
+	expectStock(t, strg, productId, 3)
+	order = expectPrice(t, strg, id, 20)
+
+	// reserving stock and adding to the total are no edits, the tags
+	// clients hold stay valid
+	after, err := getProduct(ctx, strg, productId)
+	if err != nil {
+		t.Fatalf("get product: got: %v", err)
+	}
+	if after.Version != before.Version {
+		t.Errorf("product version: got: %d, expected: %d", after.Version, before.Version)
+	}
+
+	rows, err := strg.Product().Patch(ctx, &models.PatchProduct{Id: productId, Price: &after.Price, Version: before.Version})
+	expectRows(t, "patch product after reservation", rows, err, 1)
+
+	if len(order.OrderProducts) != 1 || order.OrderProducts[0].Id != lineId || order.OrderProducts[0].TotalPrice != 20 ||
+		order.OrderProducts[0].ProductData == nil || order.OrderProducts[0].ProductData.CategoryData.Id != categoryId {
+		t.Errorf("get lines: got: %+v", order.OrderProducts)
+	}
+
+	rows, err = strg.Order().RemoveOrderItem(ctx, &models.OrderProductPrimaryKey{Id: lineId})
+	expectRows(t, "remove line", rows, err, 1)
+
+	rows, err = strg.Order().RemoveOrderItem(ctx, &models.OrderProductPrimaryKey{Id: lineId})
+	expectRows(t, "remove removed line", rows, err, 0)
+
+	expectStock(t, strg, productId, 5)
+	order = expectPrice(t, strg, id, 0)
+
+	_, err = strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 4})
+	if err != nil {
+		t.Fatalf("add line: got: %v", err)
+	}
+
+	// adding the line changed the total but not the version
+	rows, err = strg.Order().Update(ctx, &models.UpdateOrder{Id: id, ClientId: clientId, Version: order.Version})
+	expectRows(t, "update after adding a line", rows, err, 1)
+
+	_, err = strg.Order().Update(ctx, &models.UpdateOrder{Id: id, ClientId: clientId, Version: order.Version})
+	expectErr(t, "update stale version", err, storage.ErrVersionMismatch)
+
+	statuses := []struct {
+		Name    string
+		Input   string
+		WantErr error
+	}{
+		{Name: "confirm", Input: models.OrderStatusConfirmed},
+		{Name: "deliver confirmed", Input: models.OrderStatusDelivered, WantErr: storage.ErrInvalidTransition},
+		{Name: "pay", Input: models.OrderStatusPaid},
+	}
+
+	for _, status := range statuses {
+		rows, err = strg.Order().UpdateStatus(ctx, &models.UpdateOrderStatus{Id: id, Status: status.Input, ChangedBy: userId})
+		if status.WantErr != nil {
+			expectErr(t, status.Name, err, status.WantErr)
+			continue
+		}
+		expectRows(t, status.Name, rows, err, 1)
+	}
+
+	_, err = strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 1})
+	expectErr(t, "add line to paid order", err, storage.ErrOrderFrozen)
+
+	expectStock(t, strg, productId, 1)
+
+	// refunds before shipping release the stock
+	rows, err = strg.Order().UpdateStatus(ctx, &models.UpdateOrderStatus{Id: id, Status: models.OrderStatusRefunded, Reason: "test"})
+	expectRows(t, "refund", rows, err, 1)
+
+	expectStock(t, strg, productId, 5)
+
+	rows, err = strg.Order().UpdateStatus(ctx, &models.UpdateOrderStatus{Id: uuid.NewString(), Status: models.OrderStatusConfirmed})
+	expectRows(t, "update status of missing", rows, err, 0)
+
+	history, err := strg.Order().GetHistory(ctx, &models.OrderPrimaryKey{Id: id})
+	if err != nil {
+		t.Fatalf("history: got: %v", err)
+	}
+
+	// created, added, removed, added, confirmed, paid, refunded
+	if history.Count != 7 || history.History[6].FromStatus != models.OrderStatusPaid || history.History[6].Reason != "test" {
+		t.Errorf("history: got: %d entries", history.Count)
+	}
+
+	list, err := strg.Order().GetList(ctx, &models.GetListOrderRequest{Search: order.ClientData.LastName, WithProducts: true})
+	if err != nil {
+		t.Fatalf("list: got: %v", err)
+	}
+	if list.Count != 1 || list.Orders[0].Id != id || len(list.Orders[0].OrderProducts) != 1 {
+		t.Errorf("list: got: %+v", list.Orders)
+	}
+
+	rows, err = strg.Order().Delete(ctx, &models.OrderPrimaryKey{Id: id})
+	expectRows(t, "delete", rows, err, 1)
+
+	_, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: id})
+	expectErr(t, "get deleted", err, storage.ErrNotFound)
+
+	rows, err = strg.Order().Restore(ctx, &models.OrderPrimaryKey{Id: id})
+	expectRows(t, "restore", rows, err, 1)
+}
+
+// testOrderDelete checks that deleting an order does not keep its goods
+// reserved and restoring it reserves them again.
+func testOrderDelete(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	clientId, err := createClient(ctx, strg, unique("reserve"))
+	if err != nil {
+		t.Fatalf("create client: got: %v", err)
+	}
+
+	categoryId, err := createCategory(ctx, strg, unique("reserve"), "")
+	if err != nil {
+		t.Fatalf("create category: got: %v", err)
+	}
+
+	productId, err := createProduct(ctx, strg, &models.CreateProduct{
+		Name:       unique("reserve"),
+		CategoryId: categoryId,
+		Price:      10,
+		Quantity:   5,
+	})
+	if err != nil {
+		t.Fatalf("create product: got: %v", err)
+	}
+
+	id, err := strg.Order().Create(ctx, &models.CreateOrder{ClientId: clientId})
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	_, err = strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: id, ProductId: productId, Quantity: 3})
+	if err != nil {
+		t.Fatalf("add line: got: %v", err)
+	}
+
+	rows, err := strg.Order().UpdateStatus(ctx, &models.UpdateOrderStatus{Id: id, Status: models.OrderStatusConfirmed})
+	expectRows(t, "confirm", rows, err, 1)
+
+	expectStock(t, strg, productId, 2)
+
+	rows, err = strg.Order().Delete(ctx, &models.OrderPrimaryKey{Id: id})
+	expectRows(t, "delete", rows, err, 1)
+
+	expectStock(t, strg, productId, 5)
+
+	rows, err = strg.Order().Restore(ctx, &models.OrderPrimaryKey{Id: id})
+	expectRows(t, "restore", rows, err, 1)
+
+	expectStock(t, strg, productId, 2)
+
+	rows, err = strg.Order().Delete(ctx, &models.OrderPrimaryKey{Id: id})
+	expectRows(t, "delete again", rows, err, 1)
+
+	// another order takes the goods while the first one is deleted
+	otherId, err := strg.Order().Create(ctx, &models.CreateOrder{ClientId: clientId})
+	if err != nil {
+		t.Fatalf("create other: got: %v", err)
+	}
+
+	_, err = strg.Order().AddOrderProduct(ctx, &models.CreateOrderItem{OrderId: otherId, ProductId: productId, Quantity: 4})
+	if err != nil {
+		t.Fatalf("add other line: got: %v", err)
+	}
+
+	_, err = strg.Order().Restore(ctx, &models.OrderPrimaryKey{Id: id})
+	expectErr(t, "restore without stock", err, storage.ErrInsufficientStock)
+
+	_, err = strg.Order().GetByID(ctx, &models.OrderPrimaryKey{Id: id})
+	expectErr(t, "get not restored", err, storage.ErrNotFound)
+
+	expectStock(t, strg, productId, 1)
+}
+
+func expectStock(t *testing.T, strg storage.StorageI, productId string, quantity int) {
+	t.Helper()
+
+	product, err := getProduct(context.Background(), strg, productId)
+	if err != nil {
+		t.Fatalf("get product: got: %v", err)
+	}
+
+	if product.Quantity != quantity {
+		t.Errorf("stock: got: %d, expected: %d", product.Quantity, quantity)
+	}
+}
+
+func expectPrice(t *testing.T, strg storage.StorageI, orderId string, price float64) *models.Order {
+	t.Helper()
+
+	order, err := strg.Order().GetByID(context.Background(), &models.OrderPrimaryKey{Id: orderId})
+	if err != nil {
+		t.Fatalf("get order: got: %v", err)
+	}
+
+	if order.Price != price {
+		t.Errorf("price: got: %v, expected: %v", order.Price, price)
+	}
+
+	return order
+}
@@ -0,0 +1,135 @@
+// Package storagetest is a conformance suite for implementations of
+// storage.StorageI. Every implementation runs it from its own tests, so
+// they all keep the semantics handlers rely on: the storage errors, unique
+// and foreign key checks, versions, soft deletes and list filters.
+//
+// The suite only creates rows with unique names and never expects a table
+// to be empty, so it can run against a shared database.
+package storagetest
+
+import (
+	"app/storage"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Run runs the suite against the storage newStore returns, which is called
+// once per group of tests.
+func Run(t *testing.T, newStore func(t *testing.T) storage.StorageI) {
+	tests := []struct {
+		Name string
+		Test func(t *testing.T, strg storage.StorageI)
+	}{
+		{Name: "User", Test: testUser},
+		{Name: "Session", Test: testSession},
+		{Name: "Role", Test: testRole},
+		{Name: "Category", Test: testCategory},
+		{Name: "Product", Test: testProduct},
+		{Name: "Client", Test: testClient},
+		{Name: "Order", Test: testOrder},
+		{Name: "OrderDelete", Test: testOrderDelete},
+		{Name: "WithTx", Test: testWithTx},
+		{Name: "Ping", Test: testPing},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			test.Test(t, newStore(t))
+		})
+	}
+}
+
+// unique returns a word no other test run uses, made of letters and digits
+// only so that text search sees it as one word.
+func unique(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+}
+
+// expectErr fails the test when err is not target.
+func expectErr(t *testing.T, name string, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Fatalf("%s: got: %v, expected: %v", name, err, target)
+	}
+}
+
+// expectRows fails the test on an error or when the write did not affect
+// the expected number of rows.
+func expectRows(t *testing.T, name string, rows int64, err error, expected int64) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("%s: got: %v", name, err)
+	}
+
+	if rows != expected {
+		t.Fatalf("%s: got: %d rows, expected: %d", name, rows, expected)
+	}
+}
+
+func testPing(t *testing.T, strg storage.StorageI) {
+	health, err := strg.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("ping: got: %v", err)
+	}
+
+	if health.MigrationDirty {
+		t.Errorf("ping: got: dirty schema at version %d", health.MigrationVersion)
+	}
+}
+
+func testWithTx(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+	failed := errors.New("rolled back")
+
+	var committed, rolledBack, nested string
+
+	err := strg.WithTx(ctx, func(tx storage.StorageI) error {
+		var err error
+		committed, err = createClient(ctx, tx, unique("tx"))
+		if err != nil {
+			return err
+		}
+
+		// a failed nested transaction only rolls back its own writes
+		err = tx.WithTx(ctx, func(tx storage.StorageI) error {
+			nested, err = createClient(ctx, tx, unique("tx"))
+			if err != nil {
+				return err
+			}
+			return failed
+		})
+		expectErr(t, "nested", err, failed)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("commit: got: %v", err)
+	}
+
+	err = strg.WithTx(ctx, func(tx storage.StorageI) error {
+		var err error
+		rolledBack, err = createClient(ctx, tx, unique("tx"))
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	expectErr(t, "rollback", err, failed)
+
+	_, err = getClient(ctx, strg, committed)
+	if err != nil {
+		t.Errorf("committed: got: %v", err)
+	}
+
+	_, err = getClient(ctx, strg, nested)
+	expectErr(t, "nested", err, storage.ErrNotFound)
+
+	_, err = getClient(ctx, strg, rolledBack)
+	expectErr(t, "rolled back", err, storage.ErrNotFound)
+}
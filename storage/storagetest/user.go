@@ -0,0 +1,281 @@
+package storagetest
+
+import (
+	"app/api/models"
+	"app/storage"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func createUser(ctx context.Context, strg storage.StorageI, login string) (string, error) {
+	return strg.User().Create(ctx, &models.CreateUser{
+		FirstName:   "Test Name",
+		LastName:    "Test Last Name",
+		Login:       login,
+		Password:    "hash",
+		PhoneNumber: "+998933791110",
+	})
+}
+
+func testUser(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+	login := unique("user")
+
+	id, err := createUser(ctx, strg, login)
+	if err != nil {
+		t.Fatalf("create: got: %v", err)
+	}
+
+	_, err = createUser(ctx, strg, login)
+	expectErr(t, "duplicate login", err, storage.ErrConflict)
+
+	user, err := strg.User().GetByID(ctx, &models.UserPrimaryKey{Login: login})
+	if err != nil {
+		t.Fatalf("get by login: got: %v", err)
+	}
+	if user.Id != id || user.FirstName != "Test Name" || user.PhoneNumber != "+998933791110" {
+		t.Errorf("get by login: got: %+v", *user)
+	}
+
+	_, err = strg.User().GetByID(ctx, &models.UserPrimaryKey{Id: uuid.NewString()})
+	expectErr(t, "get missing", err, storage.ErrNotFound)
+
+	rows, err := strg.User().Update(ctx, &models.UpdateUser{
+		Id:          id,
+		FirstName:   "Test Name Updated",
+		LastName:    "Test Last Name",
+		Login:       login,
+		PhoneNumber: "+998933791111",
+	})
+	expectRows(t, "update", rows, err, 1)
+
+	// an empty password keeps the current one
+	credentials, err := strg.User().GetCredentials(ctx, &models.UserPrimaryKey{Login: login})
+	if err != nil {
+		t.Fatalf("credentials: got: %v", err)
+	}
+	if credentials.Id != id || credentials.Password != "hash" {
+		t.Errorf("credentials: got: %+v", *credentials)
+	}
+
+	rows, err = strg.User().UpdatePassword(ctx, &models.UpdateUserPassword{Id: id, Password: "new hash"})
+	expectRows(t, "update password", rows, err, 1)
+
+	lastName := "Patched"
+	rows, err = strg.User().Patch(ctx, &models.PatchUser{Id: id, LastName: &lastName})
+	expectRows(t, "patch", rows, err, 1)
+
+	user, err = strg.User().GetByID(ctx, &models.UserPrimaryKey{Id: id})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if user.FirstName != "Test Name Updated" || user.LastName != "Patched" || user.PhoneNumber != "+998933791111" {
+		t.Errorf("get: got: %+v", *user)
+	}
+
+	list, err := strg.User().GetList(ctx, &models.GetListUserRequest{Search: login})
+	if err != nil {
+		t.Fatalf("list: got: %v", err)
+	}
+	if list.Count != 1 || len(list.Users) != 1 || list.Users[0].Id != id {
+		t.Errorf("list: got: %d users, count %d", len(list.Users), list.Count)
+	}
+
+	rows, err = strg.User().Delete(ctx, &models.UserPrimaryKey{Id: id})
+	expectRows(t, "delete", rows, err, 1)
+
+	_, err = strg.User().GetByID(ctx, &models.UserPrimaryKey{Id: id})
+	expectErr(t, "get deleted", err, storage.ErrNotFound)
+
+	_, err = strg.User().GetCredentials(ctx, &models.UserPrimaryKey{Login: login})
+	expectErr(t, "credentials of deleted", err, storage.ErrNotFound)
+
+	user, err = strg.User().GetByID(ctx, &models.UserPrimaryKey{Id: id, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("get including deleted: got: %v", err)
+	}
+	if len(user.DeletedAt) == 0 {
+		t.Errorf("get including deleted: got no deleted_at")
+	}
+
+	// deleted users give their login up, and cannot be restored while
+	// another user holds it
+	otherId, err := createUser(ctx, strg, login)
+	if err != nil {
+		t.Fatalf("login of deleted: got: %v", err)
+	}
+
+	_, err = strg.User().Restore(ctx, &models.UserPrimaryKey{Id: id})
+	expectErr(t, "restore taken login", err, storage.ErrConflict)
+
+	rows, err = strg.User().Delete(ctx, &models.UserPrimaryKey{Id: otherId})
+	expectRows(t, "delete other", rows, err, 1)
+
+	rows, err = strg.User().Restore(ctx, &models.UserPrimaryKey{Id: id})
+	expectRows(t, "restore", rows, err, 1)
+
+	rows, err = strg.User().Restore(ctx, &models.UserPrimaryKey{Id: id})
+	expectRows(t, "restore live", rows, err, 0)
+
+	rows, err = strg.User().Delete(ctx, &models.UserPrimaryKey{Id: uuid.NewString()})
+	expectRows(t, "delete missing", rows, err, 0)
+}
+
+func testSession(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	userId, err := createUser(ctx, strg, unique("session"))
+	if err != nil {
+		t.Fatalf("create user: got: %v", err)
+	}
+
+	var (
+		familyId = uuid.NewString()
+		hashes   = []string{unique("hash"), unique("hash"), unique("hash")}
+		ids      []string
+	)
+
+	for _, hash := range hashes {
+		id, err := strg.Session().Create(ctx, &models.CreateSession{
+			UserId:           userId,
+			FamilyId:         familyId,
+			RefreshTokenHash: hash,
+			UserAgent:        "test",
+			TTL:              time.Hour,
+		})
+		if err != nil {
+			t.Fatalf("create: got: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	_, err = strg.Session().Create(ctx, &models.CreateSession{
+		UserId:           uuid.NewString(),
+		FamilyId:         familyId,
+		RefreshTokenHash: unique("hash"),
+		TTL:              time.Hour,
+	})
+	expectErr(t, "create for missing user", err, storage.ErrForeignKey)
+
+	session, err := strg.Session().GetByID(ctx, &models.SessionPrimaryKey{RefreshTokenHash: hashes[0]})
+	if err != nil {
+		t.Fatalf("get by hash: got: %v", err)
+	}
+	if session.Id != ids[0] || session.UserId != userId || session.Expired || session.Revoked {
+		t.Errorf("get by hash: got: %+v", *session)
+	}
+
+	_, err = strg.Session().GetByID(ctx, &models.SessionPrimaryKey{Id: uuid.NewString()})
+	expectErr(t, "get missing", err, storage.ErrNotFound)
+
+	rows, err := strg.Session().Revoke(ctx, &models.RevokeSession{Id: ids[0], ReplacedBy: ids[1]})
+	expectRows(t, "revoke", rows, err, 1)
+
+	// a second revoke is how token reuse is detected
+	rows, err = strg.Session().Revoke(ctx, &models.RevokeSession{Id: ids[0]})
+	expectRows(t, "revoke again", rows, err, 0)
+
+	session, err = strg.Session().GetByID(ctx, &models.SessionPrimaryKey{Id: ids[0]})
+	if err != nil {
+		t.Fatalf("get: got: %v", err)
+	}
+	if !session.Revoked || session.ReplacedBy != ids[1] {
+		t.Errorf("get revoked: got: %+v", *session)
+	}
+
+	rows, err = strg.Session().RevokeFamily(ctx, &models.RevokeSession{FamilyId: familyId})
+	expectRows(t, "revoke family", rows, err, 2)
+
+	rows, err = strg.Session().RevokeAll(ctx, &models.RevokeSession{UserId: userId})
+	expectRows(t, "revoke all", rows, err, 0)
+}
+
+func testRole(t *testing.T, strg storage.StorageI) {
+	ctx := context.Background()
+
+	admin, err := strg.Role().GetByID(ctx, &models.RolePrimaryKey{Name: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("get admin: got: %v", err)
+	}
+	if len(admin.Permissions) != 14 {
+		t.Errorf("get admin: got: %d permissions", len(admin.Permissions))
+	}
+
+	list, err := strg.Role().GetList(ctx, &models.GetListRoleRequest{})
+	if err != nil {
+		t.Fatalf("list: got: %v", err)
+	}
+	if list.Count < 3 || list.Roles[0].Name != models.RoleAdmin {
+		t.Errorf("list: got: %d roles, count %d", len(list.Roles), list.Count)
+	}
+
+	userId, err := createUser(ctx, strg, unique("role"))
+	if err != nil {
+		t.Fatalf("create user: got: %v", err)
+	}
+
+	tests := []struct {
+		Name   string
+		Input  *models.UserRole
+		Output int64
+	}{
+		{
+			Name:   "assign",
+			Input:  &models.UserRole{UserId: userId, Role: models.RoleCashier},
+			Output: 1,
+		},
+		{
+			Name:   "assign again",
+			Input:  &models.UserRole{UserId: userId, Role: models.RoleCashier},
+			Output: 0,
+		},
+		{
+			Name:   "assign missing role",
+			Input:  &models.UserRole{UserId: userId, Role: "missing"},
+			Output: 0,
+		},
+		{
+			Name:   "assign second",
+			Input:  &models.UserRole{UserId: userId, Role: models.RoleManager},
+			Output: 1,
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := strg.Role().AssignRole(ctx, test.Input)
+		expectRows(t, test.Name, rows, err, test.Output)
+	}
+
+	_, err = strg.Role().AssignRole(ctx, &models.UserRole{UserId: uuid.NewString(), Role: models.RoleCashier})
+	expectErr(t, "assign to missing user", err, storage.ErrForeignKey)
+
+	roles, err := strg.Role().GetUserRoles(ctx, &models.UserPrimaryKey{Id: userId})
+	if err != nil {
+		t.Fatalf("user roles: got: %v", err)
+	}
+	if len(roles.Roles) != 2 || roles.Roles[0] != models.RoleCashier || roles.Roles[1] != models.RoleManager || len(roles.Permissions) != 10 {
+		t.Errorf("user roles: got: %+v", *roles)
+	}
+
+	rows, err := strg.Role().RevokeRole(ctx, &models.UserRole{UserId: userId, Role: models.RoleManager})
+	expectRows(t, "revoke", rows, err, 1)
+
+	roles, err = strg.Role().GetUserRoles(ctx, &models.UserPrimaryKey{Id: userId})
+	if err != nil {
+		t.Fatalf("user roles: got: %v", err)
+	}
+	if len(roles.Roles) != 1 || len(roles.Permissions) != 3 {
+		t.Errorf("user roles after revoke: got: %+v", *roles)
+	}
+
+	roles, err = strg.Role().GetUserRoles(ctx, &models.UserPrimaryKey{Id: uuid.NewString()})
+	if err != nil {
+		t.Fatalf("roles of missing user: got: %v", err)
+	}
+	if len(roles.Roles) != 0 || roles.Permissions == nil {
+		t.Errorf("roles of missing user: got: %+v", *roles)
+	}
+}
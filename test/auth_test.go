@@ -0,0 +1,146 @@
+package test
+
+import (
+	"app/api/models"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Headers []header
+		Output  int
+	}{
+		{
+			Name:   "Logged in",
+			Output: http.StatusOK,
+		},
+		{
+			Name:    "Without token",
+			Headers: []header{{Key: "Authorization", Value: ""}},
+			Output:  http.StatusUnauthorized,
+		},
+		{
+			Name:    "Invalid token",
+			Headers: []header{{Key: "Authorization", Value: "Bearer invalid"}},
+			Output:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			resp, err := PerformRequest(http.MethodGet, "/category", nil, nil, test.Headers...)
+
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.Output, resp.StatusCode)
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	err := server.CreateUser("test_cashier", "test_password", models.RoleCashier)
+	assert.NoError(t, err)
+
+	var tokens models.LoginResponse
+	resp, err := PerformRequest(http.MethodPost, "/login", &models.Login{Login: "test_cashier", Password: "test_password"}, &tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// cashiers can not change the catalog
+	resp, err = PerformRequest(http.MethodPost, "/category", &models.CreateCategory{Name: "Test"}, nil,
+		header{Key: "Authorization", Value: "Bearer " + tokens.AccessToken})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestRevokedPermission(t *testing.T) {
+	err := server.CreateUser("test_revoked", "test_password", models.RoleManager)
+	assert.NoError(t, err)
+
+	user, err := server.Store().User().GetCredentials(context.Background(), &models.UserPrimaryKey{Login: "test_revoked"})
+	assert.NoError(t, err)
+
+	var tokens models.LoginResponse
+	resp, err := PerformRequest(http.MethodPost, "/login", &models.Login{Login: "test_revoked", Password: "test_password"}, &tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	auth := header{Key: "Authorization", Value: "Bearer " + tokens.AccessToken}
+
+	resp, err = PerformRequest(http.MethodPost, "/category", &models.CreateCategory{Name: "Test"}, nil, auth)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, err = PerformRequest(http.MethodDelete, "/user/"+user.Id+"/roles/"+models.RoleManager, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// the token still lists the role, the revocation counts right away
+	resp, err = PerformRequest(http.MethodPost, "/category", &models.CreateCategory{Name: "Test"}, nil, auth)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestLogin(t *testing.T) {
+	err := server.CreateUser("test_login", "test_password")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		Name   string
+		Input  *models.Login
+		Output int
+	}{
+		{
+			Name:   "Logged in",
+			Input:  &models.Login{Login: "test_login", Password: "test_password"},
+			Output: http.StatusCreated,
+		},
+		{
+			Name:   "Wrong password",
+			Input:  &models.Login{Login: "test_login", Password: "wrong_password"},
+			Output: http.StatusUnauthorized,
+		},
+		{
+			Name:   "Unknown login",
+			Input:  &models.Login{Login: "test_unknown", Password: "test_password"},
+			Output: http.StatusUnauthorized,
+		},
+		{
+			Name:   "Without password",
+			Input:  &models.Login{Login: "test_login"},
+			Output: http.StatusUnprocessableEntity,
+		},
+	}
+
+	var messages []string
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var res struct {
+				Data struct {
+					Message string `json:"message"`
+				}
+			}
+
+			resp, err := PerformRequest(http.MethodPost, "/login", test.Input, &res, header{Key: "Authorization", Value: ""})
+
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.Output, resp.StatusCode)
+
+			if test.Output == http.StatusUnauthorized {
+				messages = append(messages, res.Data.Message)
+			}
+		})
+	}
+
+	// nothing tells an unknown login from a wrong password
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, messages[0], messages[1])
+	}
+}
@@ -38,7 +38,7 @@ func createClient(t *testing.T) string {
 	request := &models.Client{
 		FirstName:   faker.FirstName(),
 		LastName:    faker.LastName(),
-		PhoneNumber: faker.Phonenumber(),
+		PhoneNumber: phoneNumber(),
 	}
 
 	resp, err := PerformRequest(http.MethodPost, "/client", request, response)
@@ -61,7 +61,7 @@ func updateClient(t *testing.T, id string) string {
 	request := &models.UpdateClient{
 		FirstName:   faker.FirstName(),
 		LastName:    faker.LastName(),
-		PhoneNumber: faker.Phonenumber(),
+		PhoneNumber: phoneNumber(),
 	}
 
 	resp, err := PerformRequest(http.MethodPut, "/client/"+id, request, response)
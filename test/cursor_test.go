@@ -0,0 +1,51 @@
+package test
+
+import (
+	"app/pkg/helper"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestListCursor(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Cursor string
+		Output int
+	}{
+		{
+			Name:   "First page",
+			Cursor: "",
+			Output: http.StatusOK,
+		},
+		{
+			Name:   "Next page",
+			Cursor: helper.EncodeCursor("2023-05-01 12:00:00.123456", fixtures.ProductId),
+			Output: http.StatusOK,
+		},
+		{
+			Name:   "Invalid timestamp",
+			Cursor: helper.EncodeCursor("yesterday", fixtures.ProductId),
+			Output: http.StatusBadRequest,
+		},
+		{
+			Name:   "Invalid id",
+			Cursor: helper.EncodeCursor("2023-05-01 12:00:00.123456", "not-a-uuid"),
+			Output: http.StatusBadRequest,
+		},
+	}
+
+	for _, path := range []string{"/product", "/client", "/order"} {
+		for _, test := range tests {
+			t.Run(path+" "+test.Name, func(t *testing.T) {
+				resp, err := PerformRequest(http.MethodGet, path+"?cursor="+url.QueryEscape(test.Cursor), nil, nil)
+
+				assert.NoError(t, err)
+
+				assert.Equal(t, test.Output, resp.StatusCode)
+			})
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"app/api/apitest"
+	"app/api/models"
+	"app/pkg/logger"
+	"app/storage/memory"
+	"net/http"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		Name string
+		Path string
+	}{
+		{
+			Name: "Liveness",
+			Path: "/healthz",
+		},
+		{
+			Name: "Readiness",
+			Path: "/readyz",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var health models.Health
+
+			// probes run without a token
+			resp, err := PerformRequest(http.MethodGet, test.Path, nil, &health, header{Key: "Authorization", Value: ""})
+
+			assert.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "ok", health.Status)
+		})
+	}
+}
+
+func TestReadinessShuttingDown(t *testing.T) {
+	// a server of its own, the shared one has to stay ready
+	shuttingDown := apitest.NewServer(memory.NewStore(), logger.NewLogger("test", logger.LevelError))
+	shuttingDown.ShuttingDown()
+
+	var health models.Health
+
+	resp, err := shuttingDown.Do(http.MethodGet, "/readyz", nil, &health)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// liveness does not change, the process is still fine
+	resp, err = shuttingDown.Do(http.MethodGet, "/healthz", nil, &health)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
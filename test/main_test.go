@@ -1,55 +1,102 @@
 package test
 
 import (
-	"bytes"
+	"app/api/apitest"
+	"app/api/models"
+	"app/config"
+	"app/pkg/logger"
+	"app/storage"
+	"app/storage/memory"
+	"app/storage/postgresql"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"os"
+	"testing"
 )
 
-type header struct {
-	Key   string
-	Value string
-}
+type header = apitest.Header
 
 var (
-	localhost = "http://localhost:4001"
-	testHost  = ""
+	server *apitest.Server
+
+	// rows the tests refer to, created before they run
+	fixtures struct {
+		CategoryId string
+		ClientId   string
+		ProductId  string
+	}
 )
 
-func PerformRequest(method, path string, req, res interface{}, headers ...header) (*http.Response, error) {
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
+// TestMain serves the api in process on the in-memory storage, or on the
+// database of app.env when TEST_STORAGE=postgres, logged in as an admin.
+func TestMain(m *testing.M) {
+	var store storage.StorageI = memory.NewStore()
+
+	if os.Getenv("TEST_STORAGE") == "postgres" {
+		cfg := config.Load()
+
+		var err error
+		store, err = postgresql.NewConnectPostgresql(&cfg)
+		if err != nil {
+			panic(err)
+		}
 	}
 
-	client := &http.Client{}
+	server = apitest.NewServer(store, logger.NewLogger("test", logger.LevelError))
 
-	request, err := http.NewRequestWithContext(context.Background(), method, fmt.Sprintf("%s%s", localhost, path), bytes.NewBuffer(body))
+	err := server.LoginAdmin()
 	if err != nil {
-		return nil, err
+		panic(err)
 	}
 
-	for _, h := range headers {
-		request.Header.Add(h.Key, h.Value)
+	err = createFixtures(store)
+	if err != nil {
+		panic(err)
 	}
 
-	request.Header.Add("Accept", "application/json")
+	code := m.Run()
+
+	store.CloseDB()
+	os.Exit(code)
+}
+
+func createFixtures(store storage.StorageI) error {
+	var (
+		ctx = context.Background()
+		err error
+	)
 
-	resp, err := client.Do(request)
+	fixtures.CategoryId, err = store.Category().Create(ctx, &models.CreateCategory{Name: "Test Category"})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	defer resp.Body.Close()
-	resp_body, _ := ioutil.ReadAll(resp.Body)
-
-	err = json.Unmarshal(resp_body, res)
+	fixtures.ClientId, err = store.Client().Create(ctx, &models.CreateClient{
+		FirstName:   "Test Name",
+		LastName:    "Test Last Name",
+		PhoneNumber: phoneNumber(),
+	})
 	if err != nil {
-		return resp, err
+		return err
 	}
 
-	return resp, nil
+	fixtures.ProductId, err = store.Product().Create(ctx, &models.CreateProduct{
+		Name:       "Test Product",
+		CategoryId: fixtures.CategoryId,
+		Price:      1000,
+		Quantity:   1000,
+	})
+
+	return err
+}
+
+// phoneNumber returns a random phone number in the format the api accepts.
+func phoneNumber() string {
+	return fmt.Sprintf("+99890%07d", rand.Intn(10000000))
+}
+
+func PerformRequest(method, path string, req, res interface{}, headers ...header) (*http.Response, error) {
+	return server.Do(method, path, req, res, headers...)
 }
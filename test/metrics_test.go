@@ -0,0 +1,80 @@
+package test
+
+import (
+	"app/api/models"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	// requests to a route are counted under its template whatever their
+	// status
+	categoryRequests := regexp.MustCompile(`(?m)^http_requests_total\{method="GET",route="/category/:id",status="\d{3}"\} (\S+)$`)
+
+	before := sumSamples(categoryRequests, scrapeMetrics(t))
+
+	_, err := PerformRequest(http.MethodGet, "/category/"+fixtures.CategoryId, nil, nil)
+	assert.NoError(t, err)
+
+	// not enough stock for the order
+	resp, err := PerformRequest(http.MethodPost, "/order", &models.CreateOrder{
+		ClientId: fixtures.ClientId,
+		OrderProducts: []*models.CreateOrderItem{
+			{ProductId: fixtures.ProductId, Quantity: 1000000},
+		},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	body := scrapeMetrics(t)
+
+	assert.Equal(t, before+1, sumSamples(categoryRequests, body))
+
+	tests := []struct {
+		Name   string
+		Series string // pattern of the sample line
+	}{
+		{
+			Name:   "Repository method",
+			Series: `db_query_duration_seconds_count\{method="GetByID",repo="category"\} [1-9]`,
+		},
+		{
+			Name:   "Stock out",
+			Series: `stock_outs_total [1-9]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.Regexp(t, test.Series, body)
+		})
+	}
+
+	assert.NotContains(t, body, fixtures.CategoryId)
+}
+
+func scrapeMetrics(t *testing.T) string {
+	resp, err := PerformRequest(http.MethodGet, "/metrics", nil, nil, header{Key: "Authorization", Value: ""})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	return string(body)
+}
+
+// sumSamples adds up the values of the sample lines pattern matches.
+func sumSamples(pattern *regexp.Regexp, body string) float64 {
+	var sum float64
+	for _, match := range pattern.FindAllStringSubmatch(body, -1) {
+		value, _ := strconv.ParseFloat(match[1], 64)
+		sum += value
+	}
+	return sum
+}
@@ -76,9 +76,7 @@ func createOrder(t *testing.T) string {
 	rand.Seed(time.Now().UnixNano())
 
 	request := &models.CreateOrder{
-		ClientId: "eeb13e6e-2312-43e6-a926-dc7b0ac6ff45",
-		Price:    float64(rand.Intn(1000000-100) + 100),
-		Status:   "new",
+		ClientId: fixtures.ClientId,
 	}
 	resp, err := PerformRequest(http.MethodPost, "/order", request, response)
 
@@ -98,9 +96,7 @@ func createOrder(t *testing.T) string {
 func updateOrder(t *testing.T, id string) string {
 	response := &models.Order{}
 	request := &models.UpdateOrder{
-		ClientId: "eeb13e6e-2312-43e6-a926-dc7b0ac6ff45",
-		Price:    float64(rand.Intn(1000000-100) + 100),
-		Status:   "in_proccess",
+		ClientId: fixtures.ClientId,
 	}
 
 	resp, err := PerformRequest(http.MethodPut, "/order/"+id, request, response)
@@ -141,9 +137,10 @@ func createOrderProduct(t *testing.T, orderId string) string {
 
 	request := &models.CreateOrderItem{
 		OrderId:   orderId,
-		ProductId: "30d0bcf6-460c-4213-8e28-8a3b4c7d2f68",
+		ProductId: fixtures.ProductId,
+		Quantity:  1,
 	}
-	resp, err := PerformRequest(http.MethodPost, "/order_item", request, response)
+	resp, err := PerformRequest(http.MethodPost, "/order_item/", request, response)
 
 	assert.NoError(t, err)
 
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"app/api/models"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/test-go/testify/assert"
+)
+
+func TestPatchNull(t *testing.T) {
+	id, err := server.Store().Product().Create(context.Background(), &models.CreateProduct{
+		Name:        "Patch Null",
+		CategoryId:  fixtures.CategoryId,
+		Description: "Described",
+		Price:       100,
+		Quantity:    1,
+	})
+	assert.NoError(t, err)
+
+	// null removes the member, RFC 7396
+	resp, err := PerformRequest(http.MethodPatch, "/product/"+id, map[string]interface{}{"description": nil}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var product models.Product
+
+	resp, err = PerformRequest(http.MethodGet, "/product/"+id, nil, &product)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Equal(t, "Patch Null", product.Name)
+	assert.Equal(t, "", product.Description)
+}
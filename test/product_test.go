@@ -2,9 +2,11 @@ package test
 
 import (
 	"app/api/models"
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -41,7 +43,7 @@ func createProduct(t *testing.T) string {
 
 	request := &models.CreateProduct{
 		Name:        faker.Name(),
-		CategoryId:  "c9a98d0b-8007-4698-ae1d-301e4c06c773",
+		CategoryId:  fixtures.CategoryId,
 		Description: faker.Paragraph(),
 		Price:       float64(rand.Intn(1000000-100) + 100),
 		Quantity:    rand.Intn(10-1) + 1,
@@ -62,17 +64,18 @@ func createProduct(t *testing.T) string {
 	return response.Id
 }
 
-func updateProduct(t *testing.T, id string) string {
+func updateProduct(t *testing.T, id string, version int) string {
 	response := &models.Product{}
 	request := &models.UpdateProduct{
 		Name:        faker.Name(),
-		CategoryId:  "c9a98d0b-8007-4698-ae1d-301e4c06c773",
+		CategoryId:  fixtures.CategoryId,
 		Description: faker.Paragraph(),
 		Price:       float64(rand.Intn(1000000-100) + 100),
 		Quantity:    rand.Intn(10-1) + 1,
 	}
 
-	resp, err := PerformRequest(http.MethodPut, "/product/"+id, request, response)
+	resp, err := PerformRequest(http.MethodPut, "/product/"+id, request, response,
+		header{Key: "If-Match", Value: fmt.Sprintf("%q", strconv.Itoa(version))})
 
 	assert.NoError(t, err)
 
@@ -102,3 +105,78 @@ func deleteProduct(t *testing.T, id string) string {
 
 	return ""
 }
+
+func TestProductQuantityIfMatch(t *testing.T) {
+	id, err := server.Store().Product().Create(context.Background(), &models.CreateProduct{
+		Name:       "If-Match",
+		CategoryId: fixtures.CategoryId,
+		Price:      100,
+		Quantity:   5,
+	})
+	assert.NoError(t, err)
+
+	put := &models.UpdateProduct{Name: "If-Match", CategoryId: fixtures.CategoryId, Price: 100, Quantity: 5}
+
+	// quantity is absolute, without a version it could undo the stock
+	// orders reserved since the read
+	tests := []struct {
+		Name    string
+		Method  string
+		Input   interface{}
+		IfMatch string
+		Output  int
+	}{
+		{
+			Name:   "put without If-Match",
+			Method: http.MethodPut,
+			Input:  put,
+			Output: http.StatusPreconditionRequired,
+		},
+		{
+			Name:    "put with any version",
+			Method:  http.MethodPut,
+			Input:   put,
+			IfMatch: "*",
+			Output:  http.StatusPreconditionRequired,
+		},
+		{
+			Name:   "patch quantity without If-Match",
+			Method: http.MethodPatch,
+			Input:  map[string]interface{}{"quantity": 3},
+			Output: http.StatusPreconditionRequired,
+		},
+		{
+			Name:   "patch name without If-Match",
+			Method: http.MethodPatch,
+			Input:  map[string]interface{}{"name": "Renamed"},
+			Output: http.StatusOK,
+		},
+		{
+			Name:    "patch quantity with a stale version",
+			Method:  http.MethodPatch,
+			Input:   map[string]interface{}{"quantity": 3},
+			IfMatch: `"1"`,
+			Output:  http.StatusPreconditionFailed,
+		},
+		{
+			Name:    "put with the current version",
+			Method:  http.MethodPut,
+			Input:   put,
+			IfMatch: `"2"`,
+			Output:  http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var headers []header
+			if len(test.IfMatch) > 0 {
+				headers = append(headers, header{Key: "If-Match", Value: test.IfMatch})
+			}
+
+			resp, err := PerformRequest(test.Method, "/product/"+id, test.Input, nil, headers...)
+			assert.NoError(t, err)
+			assert.Equal(t, test.Output, resp.StatusCode, "got: %v, expected: %v", resp.StatusCode, test.Output)
+		})
+	}
+}